@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// logRecord is one line of the queue's append-only log.
+// A "put" record stores a new item, an "ack" record marks it as done; anything put but never acked is pending.
+// A "fail" record keeps the error of a failed attempt so the retry history survives a restart.
+type logRecord struct {
+	Op    string        `json:"op"`
+	ID    uint64        `json:"id"`
+	Item  int           `json:"item,omitempty"`
+	Error *attemptError `json:"error,omitempty"`
+}
+
+// attemptError records why one delivery attempt of an item failed.
+type attemptError struct {
+	Attempt int       `json:"attempt"`
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error"`
+}
+
+// pendingItem is an item that was put but not yet acked.
+type pendingItem struct {
+	id       uint64
+	item     int
+	attempts int            // deliveries so far, including the current one
+	deadline time.Time      // visibility timeout while in flight
+	history  []attemptError // failed attempts, oldest first
+}
+
+// delivery is an item handed to a consumer, which must ack, nack or retry it.
+type delivery struct {
+	ID      uint64
+	Item    int
+	Attempt int            // 1 on the first delivery
+	History []attemptError // errors of the previous attempts
+	q       *durableQueue
+}
+
+// ack marks the item as processed; it is logged so the item is never delivered again, even after a restart.
+func (d delivery) ack() error { return d.q.ack(d.ID, d.Attempt) }
+
+// nack hands the item back to the queue for immediate redelivery.
+func (d delivery) nack() { d.q.nack(d.ID, d.Attempt) }
+
+// retry records err as the reason this attempt failed and redelivers the item after delay.
+func (d delivery) retry(delay time.Duration, err error) error {
+	return d.q.retry(d.ID, d.Attempt, delay, err)
+}
+
+// errQueueClosed is returned by put once the queue has been closed.
+var errQueueClosed = errors.New("queue closed")
+
+// durableQueue is a message queue that survives process crashes.
+//
+// Every put and ack is appended to a log file and synced to disk before returning.
+// On open, the log is replayed and every item without an ack is queued again.
+// A delivered item stays invisible to other consumers until it is acked, nacked,
+// or its visibility timeout expires, after which it is redelivered (at-least-once delivery).
+type durableQueue struct {
+	visibility time.Duration
+
+	mu       sync.Mutex
+	cond     *sync.Cond // consumers wait here: signalled when an item becomes ready, broadcast when the queue closes
+	settled  *sync.Cond // drain waits here: broadcast when an item is acked or the queue closes
+	log      *os.File
+	nextID   uint64
+	ready    []*pendingItem          // FIFO of items waiting for a consumer
+	inflight map[uint64]*pendingItem // delivered, waiting for ack
+	delayed  int                     // items waiting out a retry backoff
+	closed   bool
+	stop     chan struct{}
+}
+
+// openDurableQueue opens (or creates) the log at path and recovers the pending items in it.
+// The log is compacted on open so it only holds the pending items.
+func openDurableQueue(path string, visibility time.Duration) (*durableQueue, error) {
+	if visibility <= 0 {
+		return nil, fmt.Errorf("visibility timeout must be positive, got %s", visibility)
+	}
+	pending, nextID, err := replayLog(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := compactLog(path, pending); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &durableQueue{
+		visibility: visibility,
+		log:        f,
+		nextID:     nextID,
+		ready:      pending,
+		inflight:   make(map[uint64]*pendingItem),
+		stop:       make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.settled = sync.NewCond(&q.mu)
+	go q.redeliverExpired()
+	return q, nil
+}
+
+// replayLog reads the log and returns the items that were put but never acked, in put order.
+func replayLog(path string) ([]*pendingItem, uint64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 1, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	items := make(map[uint64]*pendingItem)
+	var order []uint64
+	nextID := uint64(1)
+
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		var rec logRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			// a crash mid-write can leave a torn last line; that record was never acknowledged to anyone
+			fmt.Fprintf(os.Stderr, "Queue: skipping corrupt log line %d: %v\n", line, err)
+			continue
+		}
+		switch rec.Op {
+		case "put":
+			items[rec.ID] = &pendingItem{id: rec.ID, item: rec.Item}
+			order = append(order, rec.ID)
+		case "fail":
+			if it, ok := items[rec.ID]; ok && rec.Error != nil {
+				it.history = append(it.history, *rec.Error)
+				it.attempts = rec.Error.Attempt // the next delivery continues the count
+			}
+		case "ack":
+			delete(items, rec.ID)
+		}
+		nextID = max(nextID, rec.ID+1)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var pending []*pendingItem
+	for _, id := range order {
+		if it, ok := items[id]; ok {
+			pending = append(pending, it)
+		}
+	}
+	return pending, nextID, nil
+}
+
+// compactLog atomically replaces the log with one holding only the pending items.
+func compactLog(path string, pending []*pendingItem) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, it := range pending {
+		if err := enc.Encode(logRecord{Op: "put", ID: it.id, Item: it.item}); err != nil {
+			f.Close()
+			return err
+		}
+		for _, ae := range it.history {
+			if err := enc.Encode(logRecord{Op: "fail", ID: it.id, Error: &ae}); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path) // rename is atomic, a crash leaves either the old or the new log
+}
+
+// appendLog writes one record and syncs it to disk. Callers must hold q.mu.
+func (q *durableQueue) appendLog(rec logRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := q.log.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return q.log.Sync()
+}
+
+// put durably enqueues an item; once it returns nil the item survives a crash.
+func (q *durableQueue) put(item int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errQueueClosed
+	}
+
+	id := q.nextID
+	if err := q.appendLog(logRecord{Op: "put", ID: id, Item: item}); err != nil {
+		return err
+	}
+	q.nextID++
+	q.ready = append(q.ready, &pendingItem{id: id, item: item})
+	q.cond.Signal()
+	return nil
+}
+
+// get blocks until an item is ready and delivers it, or returns false once the queue is closed.
+func (q *durableQueue) get() (delivery, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.ready) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return delivery{}, false
+	}
+
+	it := q.ready[0]
+	q.ready = q.ready[1:]
+	it.attempts++
+	it.deadline = time.Now().Add(q.visibility)
+	q.inflight[it.id] = it
+	history := append([]attemptError(nil), it.history...)
+	return delivery{ID: it.id, Item: it.item, Attempt: it.attempts, History: history, q: q}, true
+}
+
+// ack and nack only apply to the delivery identified by (id, attempt): once an item timed out
+// and was redelivered, the stale consumer no longer owns it.
+func (q *durableQueue) ack(id uint64, attempt int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if it, ok := q.inflight[id]; !ok || it.attempts != attempt {
+		return fmt.Errorf("delivery %d of item id %d is no longer in flight", attempt, id)
+	}
+	if err := q.appendLog(logRecord{Op: "ack", ID: id}); err != nil {
+		return err
+	}
+	delete(q.inflight, id)
+	q.settled.Broadcast()
+	return nil
+}
+
+func (q *durableQueue) nack(id uint64, attempt int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if it, ok := q.inflight[id]; ok && it.attempts == attempt {
+		delete(q.inflight, id)
+		q.ready = append(q.ready, it)
+		q.cond.Signal()
+	}
+}
+
+func (q *durableQueue) retry(id uint64, attempt int, delay time.Duration, cause error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, ok := q.inflight[id]
+	if !ok || it.attempts != attempt {
+		return fmt.Errorf("delivery %d of item id %d is no longer in flight", attempt, id)
+	}
+	ae := attemptError{Attempt: attempt, Time: time.Now(), Error: cause.Error()}
+	if err := q.appendLog(logRecord{Op: "fail", ID: id, Error: &ae}); err != nil {
+		return err
+	}
+	it.history = append(it.history, ae)
+	delete(q.inflight, id)
+
+	// the item is invisible for the backoff, then rejoins the back of the ready queue
+	q.delayed++
+	time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.delayed--
+		if !q.closed {
+			q.ready = append(q.ready, it)
+			q.cond.Signal() // only consumers wait on cond, so this cannot be lost on drain
+		}
+	})
+	return nil
+}
+
+// redeliverExpired periodically moves in-flight items whose visibility timeout expired back to ready.
+func (q *durableQueue) redeliverExpired() {
+	t := time.NewTicker(max(q.visibility/4, time.Millisecond)) // NewTicker panics on 0
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			q.mu.Lock()
+			var expired []*pendingItem
+			for id, it := range q.inflight {
+				if now.After(it.deadline) {
+					delete(q.inflight, id)
+					expired = append(expired, it)
+				}
+			}
+			// map order is random, keep redeliveries in put order
+			slices.SortFunc(expired, func(a, b *pendingItem) int { return cmp.Compare(a.id, b.id) })
+			for _, it := range expired {
+				fmt.Printf("Queue: item %d not acked within %s, redelivering\n", it.item, q.visibility)
+				q.ready = append(q.ready, it)
+				q.cond.Signal()
+			}
+			q.mu.Unlock()
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// pending returns the number of items not yet acked, ready, in flight or waiting to be retried.
+func (q *durableQueue) pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ready) + len(q.inflight) + q.delayed
+}
+
+// drain blocks until every item has been acked.
+//
+// It waits on its own condition: if it shared one with the consumers, a Signal meant for a consumer
+// could wake drain instead, which would go back to sleep and leave the item in ready with nobody woken.
+func (q *durableQueue) drain() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for (len(q.ready) > 0 || len(q.inflight) > 0 || q.delayed > 0) && !q.closed {
+		q.settled.Wait()
+	}
+}
+
+// close wakes all consumers and closes the log; unacked items stay in it for the next open.
+func (q *durableQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.stop)
+	q.cond.Broadcast()
+	q.settled.Broadcast()
+	return q.log.Close()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestQueue(t *testing.T, visibility time.Duration) *durableQueue {
+	t.Helper()
+	q, err := openDurableQueue(filepath.Join(t.TempDir(), "queue.log"), visibility)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.close() })
+	return q
+}
+
+// waitDrain fails the test if drain does not return within d.
+func waitDrain(t *testing.T, q *durableQueue, drained <-chan struct{}, d time.Duration) {
+	t.Helper()
+	select {
+	case <-drained:
+	case <-time.After(d):
+		q.mu.Lock()
+		ready := len(q.ready)
+		q.mu.Unlock()
+		t.Fatalf("drain never returned, %d items stuck in ready", ready)
+	}
+}
+
+// A redelivery must wake the waiting consumer, not drain, which waited first.
+func TestRedeliveryWakesConsumerNotDrain(t *testing.T) {
+	q := openTestQueue(t, 100*time.Millisecond)
+	if err := q.put(1); err != nil {
+		t.Fatal(err)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		q.drain()
+		close(drained)
+	}()
+	time.Sleep(20 * time.Millisecond) // drain is waiting
+
+	if _, ok := q.get(); !ok { // taken and never acked
+		t.Fatal("get: queue closed")
+	}
+	got := make(chan delivery)
+	go func() {
+		d, _ := q.get() // waits behind drain until the visibility timeout expires
+		got <- d
+	}()
+
+	select {
+	case d := <-got:
+		if d.Attempt != 2 {
+			t.Fatalf("redelivery attempt = %d, want 2", d.Attempt)
+		}
+		if err := d.ack(); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the waiting consumer was never woken for the redelivered item")
+	}
+	waitDrain(t, q, drained, 2*time.Second)
+}
+
+// Unacked items survive closing and reopening the log, acked ones do not.
+func TestRecoverPending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+	q, err := openDurableQueue(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if err := q.put(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, _ := q.get()
+	if err := d.ack(); err != nil {
+		t.Fatal(err)
+	}
+	q.get() // in flight when the process "dies"
+	q.close()
+
+	q, err = openDurableQueue(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+	if n := q.pending(); n != 2 {
+		t.Fatalf("pending after reopen = %d, want 2", n)
+	}
+	for _, want := range []int{1, 2} {
+		if d, _ := q.get(); d.Item != want {
+			t.Fatalf("recovered item = %d, want %d", d.Item, want)
+		}
+	}
+}
+
+// An item coming back from its retry backoff must wake the waiting consumer, not drain.
+func TestRetryWakesConsumerNotDrain(t *testing.T) {
+	q := openTestQueue(t, time.Minute)
+	if err := q.put(1); err != nil {
+		t.Fatal(err)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		q.drain()
+		close(drained)
+	}()
+	time.Sleep(20 * time.Millisecond) // drain is waiting
+
+	d, _ := q.get()
+	if err := d.retry(50*time.Millisecond, errors.New("connection reset")); err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan delivery)
+	go func() {
+		d, _ := q.get() // waits behind drain until the backoff is over
+		got <- d
+	}()
+
+	select {
+	case d := <-got:
+		if d.Attempt != 2 || len(d.History) != 1 {
+			t.Fatalf("retried delivery: attempt %d with %d errors, want attempt 2 with 1", d.Attempt, len(d.History))
+		}
+		if err := d.ack(); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the waiting consumer was never woken for the retried item")
+	}
+	waitDrain(t, q, drained, 2*time.Second)
+}
+
+func TestVisibilityMustBePositive(t *testing.T) {
+	for _, v := range []time.Duration{0, -time.Second} {
+		if q, err := openDurableQueue(filepath.Join(t.TempDir(), "queue.log"), v); err == nil {
+			q.close()
+			t.Errorf("visibility %s: opened a queue, want an error", v)
+		}
+	}
+	// shorter than a tick: the redelivery ticker must not panic
+	openTestQueue(t, 3*time.Nanosecond)
+}
@@ -0,0 +1,3 @@
+module msgqueue
+
+go 1.25.5
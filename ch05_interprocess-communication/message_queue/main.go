@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const threadNum = 4
+
+type worker struct {
+	id int
+	q  <-chan int // receive-only: worker only consumes from the queue
+}
+
+func (w *worker) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for item := range w.q {
+		fmt.Printf("Thread %d: processing item %d from the queue\n", w.id, item)
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func runMemory() {
+	// creates a queue with values to put into it for processing in the threads
+	// buffered to hold all initial messages (like python's queue)
+	q := make(chan int, 10)
+	for i := range 10 {
+		q <- i
+	}
+	close(q) // no more messages will be produced
+
+	// run threads to process data from queue
+	var wg sync.WaitGroup
+	wg.Add(threadNum)
+	for i := range threadNum {
+		w := &worker{i + 1, q}
+		go w.run(&wg)
+	}
+	wg.Wait() // block main threads until all workers finished
+}
+
+// durableWorker consumes from the durable queue; every delivery is settled by process:
+// acked on success, retried with backoff on error, or dead-lettered once attempts run out.
+type durableWorker struct {
+	id     int
+	q      *durableQueue
+	h      handler
+	policy retryPolicy
+	dlq    *deadLetterQueue
+}
+
+func (w *durableWorker) run(wg *sync.WaitGroup, acked *atomic.Int64, crashAfter int64) {
+	defer wg.Done()
+	for d, ok := w.q.get(); ok; d, ok = w.q.get() {
+		fmt.Printf("Thread %d: processing item %d from the queue (attempt %d)\n", w.id, d.Item, d.Attempt)
+
+		out, err := process(d, w.h, w.policy, w.dlq)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Thread %d: item %d: %v\n", w.id, d.Item, err)
+			continue
+		}
+		switch out {
+		case outcomeRetried:
+			fmt.Printf("Thread %d: item %d failed, retrying in %s\n", w.id, d.Item, w.policy.backoff(d.Attempt))
+			continue
+		case outcomeDeadLettered:
+			fmt.Printf("Thread %d: item %d failed %d times, moved to the dead-letter queue\n", w.id, d.Item, d.Attempt)
+		}
+
+		if n := acked.Add(1); crashAfter > 0 && n == crashAfter {
+			// simulate a crash: no cleanup, no close, the log is all that survives
+			fmt.Printf("Thread %d: crashing after %d acks!\n", w.id, n)
+			os.Exit(1)
+		}
+	}
+}
+
+// flakyHandler simulates the kinds of failures a real consumer runs into.
+func flakyHandler() handler {
+	var mu sync.Mutex
+	calls := make(map[int]int)
+
+	return func(item int) error {
+		mu.Lock()
+		calls[item]++
+		n := calls[item]
+		mu.Unlock()
+
+		time.Sleep(500 * time.Millisecond)
+		switch {
+		case item == 7:
+			return errors.New("malformed payload") // poisoned: fails every time
+		case item%5 == 3 && n <= 2:
+			return errors.New("connection reset") // transient: succeeds on the third try
+		case item%5 == 4 && n == 1:
+			// stuck consumer: outlives the visibility timeout, the item is redelivered meanwhile
+			time.Sleep(3 * time.Second)
+		}
+		return nil
+	}
+}
+
+func runDurable(path string, crashAfter int64) {
+	q, err := openDurableQueue(path, 2*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Main: open queue error: %v\n", err)
+		os.Exit(1)
+	}
+	defer q.close()
+
+	dlqPath := path + ".dead"
+	dlq, err := openDeadLetterQueue(dlqPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Main: open dead-letter queue error: %v\n", err)
+		os.Exit(1)
+	}
+	defer dlq.close()
+
+	if n := q.pending(); n > 0 {
+		fmt.Printf("Main: recovered %d pending items from %s\n", n, path)
+	} else {
+		for i := range 10 {
+			if err := q.put(i); err != nil {
+				fmt.Fprintf(os.Stderr, "Main: put error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Main: queued 10 items in %s\n", path)
+	}
+
+	h := flakyHandler()
+	policy := retryPolicy{maxAttempts: 3, baseDelay: 250 * time.Millisecond, maxDelay: 2 * time.Second}
+
+	var wg sync.WaitGroup
+	var acked atomic.Int64
+	wg.Add(threadNum)
+	for i := range threadNum {
+		w := &durableWorker{i + 1, q, h, policy, dlq}
+		go w.run(&wg, &acked, crashAfter)
+	}
+
+	q.drain() // wait until every item is acked
+	q.close() // releases the workers blocked in get
+	wg.Wait()
+	fmt.Println("Main: all items acked")
+
+	letters, err := readDeadLetters(dlqPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Main: read dead-letter queue error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, dl := range letters {
+		fmt.Printf("Dead letter: item %d (id %d)\n", dl.Item, dl.ID)
+		for _, ae := range dl.History {
+			fmt.Printf("  attempt %d at %s: %s\n", ae.Attempt, ae.Time.Format(time.TimeOnly), ae.Error)
+		}
+	}
+}
+
+func runPubSub() {
+	b := newBroker()
+
+	// "billing" has two competing consumers, "audit" and "metrics" each get their own copy of every item
+	subs := []*subscriber{
+		b.subscribe("items", "billing", "billing-1", 2, policyBlock),
+		b.subscribe("items", "billing", "billing-2", 2, policyBlock),
+		b.subscribe("items", "audit", "audit-1", 1, policyDrop),
+		b.subscribe("items", "metrics", "metrics-1", 1, policyDisconnect),
+	}
+	delays := map[string]time.Duration{
+		"billing-1": 300 * time.Millisecond,
+		"billing-2": 300 * time.Millisecond,
+		"audit-1":   1 * time.Second, // too slow: its overflow is dropped
+		"metrics-1": 2 * time.Second, // too slow: gets disconnected
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(subs))
+	for _, s := range subs {
+		go func() {
+			defer wg.Done()
+			for item := range s.C() {
+				fmt.Printf("%s: processing item %d\n", s.name, item)
+				time.Sleep(delays[s.name])
+			}
+			fmt.Printf("%s: channel closed\n", s.name)
+		}()
+	}
+
+	for i := range 10 {
+		fmt.Printf("Publisher: publishing item %d\n", i)
+		b.publish("items", i)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	time.Sleep(1 * time.Second) // let the subscribers drain their buffers
+	b.close()
+	wg.Wait()
+
+	for _, s := range subs {
+		fmt.Printf("%s (%s policy): dropped %d\n", s.name, s.policy, s.Dropped())
+	}
+}
+
+func main() {
+	path := flag.String("log", "queue.log", "path to the durable queue's append-only log")
+	crashAfter := flag.Int64("crash-after", 0, "durable mode: exit abruptly after this many acks (0 = never)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . [-log=path] [-crash-after=n] [memory|durable|pubsub]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	mode := flag.Arg(0)
+	if mode == "" {
+		mode = "memory"
+	}
+
+	switch mode {
+	case "memory":
+		runMemory()
+	case "durable":
+		runDurable(*path, *crashAfter)
+	case "pubsub":
+		runPubSub()
+	default:
+		fmt.Printf("Unknown mode %q. Use 'memory', 'durable' or 'pubsub'.\n", mode)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// slowPolicy decides what publish does when a subscriber's buffer is full.
+type slowPolicy int
+
+const (
+	policyBlock      slowPolicy = iota // wait for room: backpressure on the publisher
+	policyDrop                         // discard the message for this subscriber and count it
+	policyDisconnect                   // unsubscribe the subscriber and close its channel
+)
+
+func (p slowPolicy) String() string {
+	switch p {
+	case policyBlock:
+		return "block"
+	case policyDrop:
+		return "drop"
+	case policyDisconnect:
+		return "disconnect"
+	}
+	return fmt.Sprintf("slowPolicy(%d)", int(p))
+}
+
+// subscriber is one consumer in a subscriber group, with its own buffered channel.
+type subscriber struct {
+	name    string
+	policy  slowPolicy
+	ch      chan int
+	done    chan struct{} // closed on unsubscribe, aborts a publisher blocked on ch
+	once    sync.Once
+	dropped atomic.Int64
+
+	mu     sync.Mutex // serializes sends with closing ch
+	closed bool
+	group  *subscriberGroup
+}
+
+// C returns the channel messages are delivered on; it is closed when the subscriber is removed.
+func (s *subscriber) C() <-chan int { return s.ch }
+
+// Dropped returns how many messages were discarded because the subscriber was too slow.
+func (s *subscriber) Dropped() int64 { return s.dropped.Load() }
+
+// unsubscribe removes the subscriber from its group and closes its channel.
+func (s *subscriber) unsubscribe() {
+	s.once.Do(func() { close(s.done) }) // first, so a blocked publisher lets go of s.mu
+	s.group.remove(s)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// tryDeliver sends without blocking and reports whether the message was accepted.
+func (s *subscriber) tryDeliver(msg int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case s.ch <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver applies the subscriber's slow policy to a message that did not fit in its buffer.
+// It reports whether the group is done with the message (delivered or deliberately dropped).
+func (s *subscriber) deliver(msg int) bool {
+	if s.tryDeliver(msg) {
+		return true
+	}
+	switch s.policy {
+	case policyDrop:
+		s.dropped.Add(1)
+		return true
+	case policyDisconnect:
+		fmt.Printf("Broker: %s is too slow, disconnecting\n", s.name)
+		s.unsubscribe()
+		return false
+	default: // policyBlock
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.closed {
+			return false
+		}
+		select {
+		case s.ch <- msg:
+			return true
+		case <-s.done:
+			return false
+		}
+	}
+}
+
+// subscriberGroup is a set of competing consumers: each message goes to exactly one of them.
+type subscriberGroup struct {
+	name string
+	mu   sync.Mutex
+	subs []*subscriber
+	next int // round-robin cursor
+}
+
+func (g *subscriberGroup) add(s *subscriber) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.subs = append(g.subs, s)
+}
+
+func (g *subscriberGroup) remove(s *subscriber) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, sub := range g.subs {
+		if sub == s {
+			g.subs = append(g.subs[:i], g.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// snapshot returns the members in round-robin order starting at the next one in line.
+func (g *subscriberGroup) snapshot() []*subscriber {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := len(g.subs)
+	if n == 0 {
+		return nil
+	}
+	start := g.next % n
+	g.next = start + 1
+	return append(append([]*subscriber(nil), g.subs[start:]...), g.subs[:start]...)
+}
+
+// publish hands msg to one member: the first with room in its buffer, otherwise the first in line
+// under its slow policy. A disconnected member passes the message on to the next one.
+func (g *subscriberGroup) publish(msg int) {
+	subs := g.snapshot()
+	for _, s := range subs {
+		if s.tryDeliver(msg) {
+			return
+		}
+	}
+	for _, s := range subs {
+		if s.deliver(msg) {
+			return
+		}
+	}
+}
+
+// broker is an in-process publish/subscribe broker.
+//
+// Every topic fans out each message to all of its subscriber groups (broadcast),
+// and within a group the members compete for messages like the workers on the plain queue.
+type broker struct {
+	mu     sync.RWMutex
+	topics map[string]map[string]*subscriberGroup // topic -> group name -> group
+	closed bool
+}
+
+func newBroker() *broker {
+	return &broker{topics: make(map[string]map[string]*subscriberGroup)}
+}
+
+// subscribe joins group on topic, creating both on first use.
+// buffer is the subscriber's channel capacity, policy what happens when it is full.
+func (b *broker) subscribe(topic, group, name string, buffer int, policy slowPolicy) *subscriber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	groups, ok := b.topics[topic]
+	if !ok {
+		groups = make(map[string]*subscriberGroup)
+		b.topics[topic] = groups
+	}
+	g, ok := groups[group]
+	if !ok {
+		g = &subscriberGroup{name: group}
+		groups[group] = g
+	}
+
+	s := &subscriber{name: name, policy: policy, ch: make(chan int, buffer), done: make(chan struct{}), group: g}
+	if b.closed {
+		s.closed = true
+		close(s.ch)
+		return s
+	}
+	g.add(s)
+	return s
+}
+
+// publish broadcasts msg to every group subscribed to topic; without any group the message is discarded.
+func (b *broker) publish(topic string, msg int) {
+	b.mu.RLock()
+	groups := make([]*subscriberGroup, 0, len(b.topics[topic]))
+	for _, g := range b.topics[topic] {
+		groups = append(groups, g)
+	}
+	b.mu.RUnlock()
+
+	for _, g := range groups {
+		g.publish(msg)
+	}
+}
+
+// close unsubscribes everyone, closing all subscriber channels.
+func (b *broker) close() {
+	b.mu.Lock()
+	b.closed = true
+	var all []*subscriber
+	for _, groups := range b.topics {
+		for _, g := range groups {
+			g.mu.Lock()
+			all = append(all, g.subs...)
+			g.mu.Unlock()
+		}
+	}
+	b.mu.Unlock()
+
+	for _, s := range all {
+		s.unsubscribe()
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// drain returns what is buffered on s without waiting for more.
+func drain(s *subscriber) []int {
+	var got []int
+	for {
+		select {
+		case msg, ok := <-s.C():
+			if !ok {
+				return got
+			}
+			got = append(got, msg)
+		default:
+			return got
+		}
+	}
+}
+
+// Within a group each message reaches one member, and every group gets every message.
+func TestGroupMembersCompete(t *testing.T) {
+	b := newBroker()
+	defer b.close()
+	billing := []*subscriber{
+		b.subscribe("orders", "billing", "billing-1", 100, policyBlock),
+		b.subscribe("orders", "billing", "billing-2", 100, policyBlock),
+		b.subscribe("orders", "billing", "billing-3", 100, policyBlock),
+	}
+	audit := b.subscribe("orders", "audit", "audit-1", 100, policyBlock)
+	other := b.subscribe("payments", "audit", "audit-2", 100, policyBlock)
+
+	var want []int
+	for i := range 30 {
+		b.publish("orders", i)
+		want = append(want, i)
+	}
+
+	var got []int
+	for _, s := range billing {
+		msgs := drain(s)
+		if len(msgs) == 0 {
+			t.Errorf("%s received nothing, the group should share the messages", s.name)
+		}
+		got = append(got, msgs...)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("billing group received %v, want each of 0..29 exactly once", got)
+	}
+	if got := drain(audit); !slices.Equal(got, want) {
+		t.Errorf("audit group received %v, want every message in order", got)
+	}
+	if got := drain(other); len(got) != 0 {
+		t.Errorf("a subscriber of another topic received %v", got)
+	}
+}
+
+func TestPolicyDrop(t *testing.T) {
+	b := newBroker()
+	defer b.close()
+	s := b.subscribe("ticks", "g", "never-reads", 2, policyDrop)
+
+	for i := range 5 {
+		b.publish("ticks", i) // must not block
+	}
+	if n := s.Dropped(); n != 3 {
+		t.Errorf("dropped %d messages, want 3", n)
+	}
+	if got := drain(s); !slices.Equal(got, []int{0, 1}) {
+		t.Errorf("buffered %v, want the first two", got)
+	}
+}
+
+func TestPolicyDisconnect(t *testing.T) {
+	b := newBroker()
+	defer b.close()
+	slow := b.subscribe("ticks", "slow", "never-reads", 1, policyDisconnect)
+	fast := b.subscribe("ticks", "fast", "fast-1", 10, policyBlock)
+
+	for i := range 3 {
+		b.publish("ticks", i)
+	}
+	if got := drain(slow); !slices.Equal(got, []int{0}) {
+		t.Errorf("slow subscriber got %v before being disconnected, want [0]", got)
+	}
+	if _, ok := <-slow.C(); ok {
+		t.Error("the slow subscriber's channel is still open")
+	}
+	if got := drain(fast); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("the other group got %v, want all three", got)
+	}
+}
+
+func TestPolicyBlock(t *testing.T) {
+	b := newBroker()
+	defer b.close()
+	s := b.subscribe("ticks", "g", "never-reads", 1, policyBlock)
+	b.publish("ticks", 0) // fills the buffer
+
+	published := make(chan struct{})
+	go func() {
+		b.publish("ticks", 1)
+		close(published)
+	}()
+	select {
+	case <-published:
+		t.Fatal("publish returned while the subscriber's buffer was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if msg := <-s.C(); msg != 0 {
+		t.Fatalf("received %d, want 0", msg)
+	}
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish still blocked after the subscriber made room")
+	}
+	if msg := <-s.C(); msg != 1 {
+		t.Errorf("received %d, want 1", msg)
+	}
+
+	// a publisher blocked on a subscriber that leaves is let go
+	b.publish("ticks", 2)
+	published = make(chan struct{})
+	go func() {
+		b.publish("ticks", 3)
+		close(published)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish still blocked after the subscriber unsubscribed")
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := newBroker()
+	s := b.subscribe("ticks", "g", "s", 1, policyBlock)
+	b.close()
+	if _, ok := <-s.C(); ok {
+		t.Error("subscriber channel still open after close")
+	}
+	late := b.subscribe("ticks", "g", "late", 1, policyBlock)
+	if _, ok := <-late.C(); ok {
+		t.Error("subscribing to a closed broker returned an open channel")
+	}
+	b.publish("ticks", 1) // nobody left, and no panic on a closed channel
+}
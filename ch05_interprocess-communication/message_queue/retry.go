@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// handler processes one item; a non-nil error means the attempt failed and the item should be retried.
+type handler func(item int) error
+
+// retryPolicy controls how failed items are retried before they are dead-lettered.
+type retryPolicy struct {
+	maxAttempts int           // total attempts, including the first one
+	baseDelay   time.Duration // backoff after the first failure
+	maxDelay    time.Duration // backoff cap
+}
+
+// backoff returns the delay before the next attempt: baseDelay doubled per failure, capped at maxDelay.
+func (p retryPolicy) backoff(failedAttempt int) time.Duration {
+	d := p.baseDelay
+	for i := 1; i < failedAttempt && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.maxDelay)
+}
+
+// deadLetter is a poisoned item that exhausted its attempts, with the errors of every one of them.
+type deadLetter struct {
+	ID      uint64         `json:"id"`
+	Item    int            `json:"item"`
+	History []attemptError `json:"history"`
+	DeadAt  time.Time      `json:"dead_at"`
+}
+
+// deadLetterQueue appends dead letters to a file, one JSON object per line, for later inspection or replay.
+type deadLetterQueue struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func openDeadLetterQueue(path string) (*deadLetterQueue, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &deadLetterQueue{f: f}, nil
+}
+
+// add durably records a dead letter; only after it returns may the item be acked off the main queue.
+func (dlq *deadLetterQueue) add(dl deadLetter) error {
+	b, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	if _, err := dlq.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return dlq.f.Sync()
+}
+
+func (dlq *deadLetterQueue) close() error { return dlq.f.Close() }
+
+// readDeadLetters loads every dead letter recorded in the file at path.
+func readDeadLetters(path string) ([]deadLetter, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var letters []deadLetter
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var dl deadLetter
+		if err := json.Unmarshal(sc.Bytes(), &dl); err != nil {
+			return nil, err
+		}
+		letters = append(letters, dl)
+	}
+	return letters, sc.Err()
+}
+
+// outcome is how process settled a delivery.
+type outcome int
+
+const (
+	outcomeAcked        outcome = iota // handler succeeded
+	outcomeRetried                     // handler failed, the item comes back after a backoff
+	outcomeDeadLettered                // handler failed on the last attempt, the item left the queue
+)
+
+// process runs h on a delivery and settles it:
+// success acks, failure retries after a backoff, and the last allowed failure dead-letters the item.
+// A dead letter is written before the ack, so a crash in between duplicates it rather than losing it.
+func process(d delivery, h handler, policy retryPolicy, dlq *deadLetterQueue) (outcome, error) {
+	herr := h(d.Item)
+	if herr == nil {
+		return outcomeAcked, d.ack()
+	}
+
+	if d.Attempt < policy.maxAttempts {
+		return outcomeRetried, d.retry(policy.backoff(d.Attempt), herr)
+	}
+
+	history := append(d.History, attemptError{Attempt: d.Attempt, Time: time.Now(), Error: herr.Error()})
+	if err := dlq.add(deadLetter{ID: d.ID, Item: d.Item, History: history, DeadAt: time.Now()}); err != nil {
+		return outcomeDeadLettered, err
+	}
+	return outcomeDeadLettered, d.ack()
+}
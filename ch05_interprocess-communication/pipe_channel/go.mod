@@ -0,0 +1,3 @@
+module pipechannel
+
+go 1.25.5
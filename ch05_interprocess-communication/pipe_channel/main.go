@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var buffer = flag.Int("buffer", 2, "channel buffer size: 0 makes every send wait for a reader")
+
+// writer streams its messages into a channel, then closes it: closing is how a channel says
+// "no more messages", like EOF on a pipe. Only the writer may close, and only once.
+type writer[T any] struct {
+	name string
+	ch   chan<- T // send-only channel
+	msgs []T
+	done <-chan struct{} // closed when the readers give up, so the writer does not block forever
+}
+
+func (w *writer[T]) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer close(w.ch)
+	for _, msg := range w.msgs {
+		select {
+		case w.ch <- msg:
+			fmt.Printf("%s: Sent: %v\n", w.name, msg)
+			continue
+		default:
+		}
+		// the buffer is full (or there is none): the send blocks until a reader catches up
+		fmt.Printf("%s: Waiting for a reader (backpressure)...\n", w.name)
+		select {
+		case w.ch <- msg:
+			fmt.Printf("%s: Sent: %v\n", w.name, msg)
+		case <-w.done:
+			fmt.Printf("%s: Readers gone, stopping\n", w.name)
+			return
+		}
+	}
+}
+
+// reader receives until the channel is closed and drained.
+type reader[T any] struct {
+	name string
+	ch   <-chan T // receive-only channel
+}
+
+func (r *reader[T]) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	fmt.Printf("%s: Reading...\n", r.name)
+	for msg := range r.ch {
+		fmt.Printf("%s: Received: %v\n", r.name, msg)
+	}
+	fmt.Printf("%s: Channel closed\n", r.name)
+}
+
+// merge fans in: everything sent on any of ins comes out of the returned channel, buffered by size,
+// which is closed once all of ins are. Closing done stops it early, even while an input is still open.
+func merge[T any](done <-chan struct{}, size int, ins ...<-chan T) <-chan T {
+	out := make(chan T, size)
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var msg T
+				var ok bool
+				select {
+				case msg, ok = <-in:
+					if !ok {
+						return
+					}
+				case <-done:
+					return
+				}
+				select {
+				case out <- msg:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// broadcast fans out: every message from in is delivered to each of n channels, buffered by size,
+// so the slowest reader sets the pace for all. They are closed once in is. Closing done stops it early.
+func broadcast[T any](done <-chan struct{}, in <-chan T, n, size int) []<-chan T {
+	outs := make([]chan T, n)
+	ro := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T, size)
+		ro[i] = outs[i]
+	}
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for {
+			var msg T
+			var ok bool
+			select {
+			case msg, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-done:
+				return
+			}
+			for _, out := range outs {
+				select {
+				case out <- msg:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return ro
+}
+
+// checkLeaks reports whether every goroutine a scenario started has exited.
+// Goroutines finish shortly after signalling, so allow them a moment.
+func checkLeaks(baseline int) {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		fmt.Printf("LEAK: %d goroutines still running\n\n", n-baseline)
+		return
+	}
+	fmt.Print("No goroutines left behind.\n\n")
+}
+
+var ducks = []string{"Rubber duck", "Wooden duck", "Plastic duck", "Last duck"}
+
+func main() {
+	flag.Parse()
+	// a channel can be thought of as a pipe-like conduit between goroutines
+	baseline := runtime.NumGoroutine()
+	never := make(chan struct{}) // for scenarios that run to completion
+
+	fmt.Printf("=== one writer, one reader, buffer %d ===\n", *buffer)
+	{
+		ch := make(chan string, *buffer)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go (&writer[string]{name: "Writer", ch: ch, msgs: ducks, done: never}).run(&wg)
+		go (&reader[string]{name: "Reader", ch: ch}).run(&wg)
+		wg.Wait() // block main until child goroutines finish
+	}
+	checkLeaks(baseline)
+
+	fmt.Println("=== fan-in: three writers, merged into one reader ===")
+	{
+		var wg sync.WaitGroup
+		var ins []<-chan int
+		for i := range 3 {
+			ch := make(chan int, *buffer)
+			ins = append(ins, ch)
+			msgs := []int{(i + 1) * 10, (i+1)*10 + 1}
+			wg.Add(1)
+			go (&writer[int]{name: fmt.Sprintf("Writer %d", i+1), ch: ch, msgs: msgs, done: never}).run(&wg)
+		}
+		wg.Add(1)
+		go (&reader[int]{name: "Reader", ch: merge(never, *buffer, ins...)}).run(&wg)
+		wg.Wait()
+	}
+	checkLeaks(baseline)
+
+	fmt.Println("=== fan-out: one writer, broadcast to three readers ===")
+	{
+		ch := make(chan string, *buffer)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go (&writer[string]{name: "Writer", ch: ch, msgs: ducks, done: never}).run(&wg)
+		for i, out := range broadcast(never, ch, 3, *buffer) {
+			wg.Add(1)
+			go (&reader[string]{name: fmt.Sprintf("Reader %d", i+1), ch: out}).run(&wg)
+		}
+		wg.Wait()
+	}
+	checkLeaks(baseline)
+
+	// A reader that stops early must tell everyone upstream, or they block on their next send forever.
+	fmt.Println("=== early stop: the reader takes two messages, then closes done ===")
+	{
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		var ins []<-chan string
+		for i := range 2 {
+			ch := make(chan string) // unbuffered, so the writers are surely blocked when we stop
+			ins = append(ins, ch)
+			wg.Add(1)
+			go (&writer[string]{name: fmt.Sprintf("Writer %d", i+1), ch: ch, msgs: ducks, done: done}).run(&wg)
+		}
+		merged := merge(done, *buffer, ins...)
+		for range 2 {
+			fmt.Printf("Reader: Received: %v\n", <-merged)
+		}
+		fmt.Println("Reader: Had enough, closing done")
+		close(done)
+		wg.Wait()
+	}
+	checkLeaks(baseline)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// expectNoLeaks fails t unless the goroutine count drops back to baseline within a second.
+func expectNoLeaks(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		buf := make([]byte, 1<<16)
+		t.Fatalf("%d goroutines still running:\n%s", n-baseline, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+// source sends msgs on a fresh channel and closes it.
+func source(msgs ...int) <-chan int {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for _, m := range msgs {
+			ch <- m
+		}
+	}()
+	return ch
+}
+
+func TestMergeDeliversAll(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	for _, size := range []int{0, 1, 4} {
+		var got []int
+		for m := range merge(nil, size, source(1, 2, 3), source(4, 5), source()) {
+			got = append(got, m)
+		}
+		slices.Sort(got)
+		if want := []int{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+			t.Errorf("size %d: got %v, want %v", size, got, want)
+		}
+	}
+	expectNoLeaks(t, baseline)
+}
+
+func TestBroadcastDeliversAll(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	for _, size := range []int{0, 1, 4} {
+		outs := broadcast(nil, source(1, 2, 3), 3, size)
+		results := make(chan []int)
+		for _, out := range outs {
+			go func() {
+				var got []int
+				for m := range out {
+					got = append(got, m)
+				}
+				results <- got
+			}()
+		}
+		for range outs {
+			if got, want := <-results, []int{1, 2, 3}; !slices.Equal(got, want) {
+				t.Errorf("size %d: got %v, want %v", size, got, want)
+			}
+		}
+	}
+	expectNoLeaks(t, baseline)
+}
+
+func TestMergeEarlyStopLeavesNoGoroutines(t *testing.T) {
+	for _, size := range []int{0, 2} {
+		baseline := runtime.NumGoroutine()
+		done := make(chan struct{})
+		busy := make(chan int) // a writer that never closes: blocked on a send when we stop
+		go func() {
+			for i := 0; ; i++ {
+				select {
+				case busy <- i:
+				case <-done:
+					return
+				}
+			}
+		}()
+		idle := make(chan int) // an input that never sends nor closes
+		merged := merge(done, size, busy, idle)
+		<-merged
+		<-merged
+		close(done)
+
+		expectNoLeaks(t, baseline)
+		for range merged { // whatever was buffered, then closed
+		}
+	}
+}
+
+func TestBroadcastEarlyStopLeavesNoGoroutines(t *testing.T) {
+	for _, size := range []int{0, 2} {
+		baseline := runtime.NumGoroutine()
+		done := make(chan struct{})
+		in := make(chan int, 8) // never closed
+		for i := range 8 {
+			in <- i
+		}
+		outs := broadcast(done, in, 3, size)
+		<-outs[0] // only one reader ever shows up, so broadcast blocks on the others
+		close(done)
+
+		expectNoLeaks(t, baseline)
+		for _, out := range outs {
+			for range out {
+			}
+		}
+	}
+
+	// the input stays open and silent: broadcast is waiting to receive, not to send
+	baseline := runtime.NumGoroutine()
+	done := make(chan struct{})
+	outs := broadcast(done, make(chan int), 2, 0)
+	close(done)
+	expectNoLeaks(t, baseline)
+	for _, out := range outs {
+		if _, ok := <-out; ok {
+			t.Error("got a message from an idle input")
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"pipes/framing"
+)
+
+// The benchmark streams n messages of a fixed size through each IPC mechanism of chapter 5
+// and measures, on the receiving side, the one-way latency of every message and the overall throughput.
+// Each message starts with its send time (UnixNano), so latency includes any time spent queued
+// in kernel or channel buffers while the sender runs ahead of the receiver.
+// Byte-stream transports carry length-prefixed frames, the channel carries the slices themselves.
+
+const timestampSize = 8
+
+// benchStats summarizes one benchmark run.
+type benchStats struct {
+	transport, mode string
+	n, size         int
+	p50, p90, p99   time.Duration
+	max             time.Duration
+	elapsed         time.Duration
+}
+
+func summarize(transport, mode string, size int, latencies []time.Duration, elapsed time.Duration) benchStats {
+	slices.Sort(latencies)
+	pct := func(p float64) time.Duration {
+		if len(latencies) == 0 {
+			return 0
+		}
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	return benchStats{
+		transport: transport, mode: mode, n: len(latencies), size: size,
+		p50: pct(0.50), p90: pct(0.90), p99: pct(0.99), max: pct(1),
+		elapsed: elapsed,
+	}
+}
+
+// newBenchMessage returns a message of size bytes stamped with the current time.
+func newBenchMessage(size int) []byte {
+	msg := make([]byte, max(size, timestampSize))
+	binary.BigEndian.PutUint64(msg, uint64(time.Now().UnixNano()))
+	return msg
+}
+
+func messageLatency(msg []byte) time.Duration {
+	sent := int64(binary.BigEndian.Uint64(msg))
+	return time.Duration(time.Now().UnixNano() - sent)
+}
+
+// sendFrames writes n stamped messages to w, then closes it to signal the end of the stream.
+func sendFrames(w io.WriteCloser, n, size int) error {
+	defer w.Close()
+	fw := framing.Length.NewWriter(w)
+	for range n {
+		if err := fw.WriteFrame(newBenchMessage(size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiveFrames reads frames until EOF and returns the latency of each one,
+// plus the time from the first to the last message.
+func receiveFrames(r io.Reader) ([]time.Duration, time.Duration, error) {
+	fr := framing.Length.NewReader(r)
+	var latencies []time.Duration
+	var first time.Time
+	for {
+		msg, err := fr.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		lat := messageLatency(msg)
+		if first.IsZero() {
+			first = time.Now().Add(-lat) // when the first message was sent
+		}
+		latencies = append(latencies, lat)
+	}
+	return latencies, time.Since(first), nil
+}
+
+// --------------------
+// In-process: sender and receiver are goroutines
+// --------------------
+
+func benchChannel(n, size int) (benchStats, error) {
+	// buffered like a pipe, so the sender may run ahead of the receiver
+	ch := make(chan []byte, 64)
+	go func() {
+		for range n {
+			ch <- newBenchMessage(size)
+		}
+		close(ch)
+	}()
+
+	latencies := make([]time.Duration, 0, n)
+	var first time.Time
+	for msg := range ch {
+		lat := messageLatency(msg)
+		if first.IsZero() {
+			first = time.Now().Add(-lat)
+		}
+		latencies = append(latencies, lat)
+	}
+	return summarize("channel", "in-process", size, latencies, time.Since(first)), nil
+}
+
+// benchStream runs sender and receiver goroutines over an already connected byte stream.
+func benchStream(name string, w io.WriteCloser, r io.ReadCloser, n, size int) (benchStats, error) {
+	defer r.Close()
+	errc := make(chan error, 1)
+	go func() { errc <- sendFrames(w, n, size) }()
+
+	latencies, elapsed, err := receiveFrames(r)
+	if err := errors.Join(err, <-errc); err != nil {
+		return benchStats{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return summarize(name, "in-process", size, latencies, elapsed), nil
+}
+
+func benchPipe(n, size int) (benchStats, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return benchStats{}, err
+	}
+	return benchStream("pipe", w, r, n, size)
+}
+
+func benchUnixSocket(dir string, n, size int) (benchStats, error) {
+	path := filepath.Join(dir, "bench.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return benchStats{}, err
+	}
+	defer ln.Close()
+
+	w, err := net.Dial("unix", path)
+	if err != nil {
+		return benchStats{}, err
+	}
+	r, err := ln.Accept()
+	if err != nil {
+		w.Close()
+		return benchStats{}, err
+	}
+	return benchStream("unix socket", w, r, n, size)
+}
+
+// --------------------
+// Cross-process: the sender is a child process, the receiver stays in the parent
+// --------------------
+
+// benchChild starts this program as a sender for transport, writing to the given target.
+func benchChild(transport, target string, n, size int, extra *os.File) *exec.Cmd {
+	cmd := exec.Command(os.Args[0],
+		"-role=bench-sender", "-transport="+transport, "-path="+target,
+		"-n="+strconv.Itoa(n), "-size="+strconv.Itoa(size), "bench")
+	cmd.Stderr = os.Stderr
+	if extra != nil {
+		cmd.ExtraFiles = []*os.File{extra} // becomes fd 3 in the child
+	}
+	return cmd
+}
+
+func benchPipeProcess(n, size int) (benchStats, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return benchStats{}, err
+	}
+	defer r.Close()
+
+	cmd := benchChild("pipe", "", n, size, w)
+	err = cmd.Start()
+	w.Close() // the parent must drop its copy of the write end, otherwise the reader never sees EOF
+	if err != nil {
+		return benchStats{}, err
+	}
+
+	latencies, elapsed, err := receiveFrames(r)
+	if err := errors.Join(err, cmd.Wait()); err != nil {
+		return benchStats{}, fmt.Errorf("pipe: %w", err)
+	}
+	return summarize("pipe", "cross-process", size, latencies, elapsed), nil
+}
+
+func benchUnixSocketProcess(dir string, n, size int) (benchStats, error) {
+	path := filepath.Join(dir, "bench-xp.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return benchStats{}, err
+	}
+	defer ln.Close()
+
+	cmd := benchChild("unix", path, n, size, nil)
+	if err := cmd.Start(); err != nil {
+		return benchStats{}, err
+	}
+	r, err := ln.Accept()
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return benchStats{}, err
+	}
+	defer r.Close()
+
+	latencies, elapsed, err := receiveFrames(r)
+	if err := errors.Join(err, cmd.Wait()); err != nil {
+		return benchStats{}, fmt.Errorf("unix socket: %w", err)
+	}
+	return summarize("unix socket", "cross-process", size, latencies, elapsed), nil
+}
+
+// runBenchSender is the child side of the cross-process benchmarks.
+func runBenchSender(transport, target string, n, size int) {
+	var w io.WriteCloser
+	var err error
+	switch transport {
+	case "pipe":
+		w = os.NewFile(3, "pipe") // inherited through cmd.ExtraFiles
+	case "fifo":
+		w, err = os.OpenFile(target, os.O_WRONLY, 0)
+	case "unix":
+		w, err = net.Dial("unix", target)
+	default:
+		err = fmt.Errorf("unknown transport %q", transport)
+	}
+	if err == nil {
+		err = sendFrames(w, n, size)
+	}
+	if err != nil {
+		printAndExit("Sender: "+err.Error(), 1)
+	}
+}
+
+// runBench runs every transport in both modes and prints one table.
+func runBench(n, size int) {
+	dir, err := os.MkdirTemp("", "pipes-bench")
+	if err != nil {
+		printAndExit("Bench: "+err.Error(), 1)
+	}
+	defer os.RemoveAll(dir)
+
+	fmt.Printf("Streaming %d messages of %d bytes through each transport\n\n", n, max(size, timestampSize))
+
+	runs := []func() (benchStats, error){
+		func() (benchStats, error) { return benchChannel(n, size) },
+		func() (benchStats, error) { return benchPipe(n, size) },
+		func() (benchStats, error) { return benchFIFO(dir, n, size) },
+		func() (benchStats, error) { return benchUnixSocket(dir, n, size) },
+		func() (benchStats, error) { return benchPipeProcess(n, size) },
+		func() (benchStats, error) { return benchFIFOProcess(dir, n, size) },
+		func() (benchStats, error) { return benchUnixSocketProcess(dir, n, size) },
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "transport\tmode\tmsgs\tp50\tp90\tp99\tmax\tmsgs/s\tMB/s\t")
+	for _, run := range runs {
+		s, err := run()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Bench: %v\n", err)
+			continue
+		}
+		secs := s.elapsed.Seconds()
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%.0f\t%.1f\t\n",
+			s.transport, s.mode, s.n, s.p50, s.p90, s.p99, s.max,
+			float64(s.n)/secs, float64(s.n*max(s.size, timestampSize))/secs/1e6)
+	}
+	tw.Flush()
+	fmt.Println("\nThe channel has no cross-process counterpart: it only exists inside one Go program.")
+}
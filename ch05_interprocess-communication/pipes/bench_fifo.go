@@ -0,0 +1,67 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+func benchFIFO(dir string, n, size int) (benchStats, error) {
+	path := filepath.Join(dir, "bench.fifo")
+	if err := syscall.Mkfifo(path, 0666); err != nil {
+		return benchStats{}, err
+	}
+	defer os.Remove(path)
+
+	// both opens block until the other side arrives, so open them concurrently
+	wc := make(chan *os.File, 1)
+	errc := make(chan error, 1)
+	go func() {
+		w, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			errc <- err
+			return
+		}
+		wc <- w
+	}()
+	r, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return benchStats{}, err
+	}
+	select {
+	case w := <-wc:
+		return benchStream("fifo", w, r, n, size)
+	case err := <-errc:
+		r.Close()
+		return benchStats{}, err
+	}
+}
+
+func benchFIFOProcess(dir string, n, size int) (benchStats, error) {
+	path := filepath.Join(dir, "bench-xp.fifo")
+	if err := syscall.Mkfifo(path, 0666); err != nil {
+		return benchStats{}, err
+	}
+	defer os.Remove(path)
+
+	cmd := benchChild("fifo", path, n, size, nil)
+	if err := cmd.Start(); err != nil {
+		return benchStats{}, err
+	}
+	r, err := os.OpenFile(path, os.O_RDONLY, 0) // blocks until the child opens the write end
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return benchStats{}, err
+	}
+	defer r.Close()
+
+	latencies, elapsed, err := receiveFrames(r)
+	if err := errors.Join(err, cmd.Wait()); err != nil {
+		return benchStats{}, fmt.Errorf("fifo: %w", err)
+	}
+	return summarize("fifo", "cross-process", size, latencies, elapsed), nil
+}
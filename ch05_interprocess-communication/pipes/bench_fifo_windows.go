@@ -0,0 +1,9 @@
+package main
+
+import "errors"
+
+var errNoFIFO = errors.New("fifo: named pipes (mkfifo) are not available on Windows")
+
+func benchFIFO(dir string, n, size int) (benchStats, error) { return benchStats{}, errNoFIFO }
+
+func benchFIFOProcess(dir string, n, size int) (benchStats, error) { return benchStats{}, errNoFIFO }
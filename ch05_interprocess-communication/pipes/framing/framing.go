@@ -0,0 +1,250 @@
+// Package framing splits a byte stream (pipe, FIFO, socket) into discrete messages.
+//
+// Pipes only move bytes: a single Write may arrive in several Reads, and several Writes may
+// arrive in one. A frame codec puts the message boundaries back. Three codecs are provided:
+//
+//   - Length: a 4-byte big-endian length, then the payload. Any bytes, fixed overhead.
+//   - Varint: an unsigned varint length, then the payload. Any bytes, 1 byte overhead for small frames.
+//   - NDJSON: one JSON value per line. Human-readable, but payloads must be JSON.
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxFrameSize bounds the size of a single frame, so a corrupt or hostile length prefix
+// cannot make a reader allocate arbitrary amounts of memory.
+const MaxFrameSize = 16 << 20
+
+// ErrFrameTooLarge is returned when a frame exceeds MaxFrameSize.
+var ErrFrameTooLarge = errors.New("framing: frame too large")
+
+// FrameWriter writes whole frames; each WriteFrame call is one message on the other side.
+type FrameWriter interface {
+	WriteFrame(p []byte) error
+}
+
+// FrameReader reads whole frames. It returns io.EOF at a clean frame boundary
+// and io.ErrUnexpectedEOF if the stream ends in the middle of a frame.
+type FrameReader interface {
+	ReadFrame() ([]byte, error)
+}
+
+// Codec creates matching frame readers and writers.
+type Codec interface {
+	Name() string
+	NewReader(r io.Reader) FrameReader
+	NewWriter(w io.Writer) FrameWriter
+}
+
+var (
+	Length Codec = lengthCodec{}
+	Varint Codec = varintCodec{}
+	NDJSON Codec = ndjsonCodec{}
+)
+
+// ByName returns the codec called name: "length", "varint" or "ndjson".
+func ByName(name string) (Codec, error) {
+	for _, c := range []Codec{Length, Varint, NDJSON} {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("framing: unknown codec %q", name)
+}
+
+// WriteJSON marshals v and writes it as one frame.
+func WriteJSON(fw FrameWriter, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return fw.WriteFrame(b)
+}
+
+// ReadJSON reads one frame and unmarshals it into v.
+func ReadJSON(fr FrameReader, v any) error {
+	b, err := fr.ReadFrame()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// --------------------
+// Length-prefixed
+// --------------------
+
+type lengthCodec struct{}
+
+func (lengthCodec) Name() string                      { return "length" }
+func (lengthCodec) NewReader(r io.Reader) FrameReader { return &lengthReader{r: bufio.NewReader(r)} }
+func (lengthCodec) NewWriter(w io.Writer) FrameWriter { return &lengthWriter{w: w} }
+
+type lengthWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (lw *lengthWriter) WriteFrame(p []byte) error {
+	if len(p) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	// header and payload in one Write, so concurrent writers to a pipe do not interleave them
+	// (writes up to PIPE_BUF bytes are atomic)
+	lw.buf = binary.BigEndian.AppendUint32(lw.buf[:0], uint32(len(p)))
+	lw.buf = append(lw.buf, p...)
+	_, err := lw.w.Write(lw.buf)
+	return err
+}
+
+type lengthReader struct {
+	r *bufio.Reader
+}
+
+func (lr *lengthReader) ReadFrame() ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(lr.r, hdr[:]); err != nil {
+		return nil, err // io.EOF only if no header byte was read
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	return readPayload(lr.r, int(n))
+}
+
+// --------------------
+// Varint-prefixed
+// --------------------
+
+type varintCodec struct{}
+
+func (varintCodec) Name() string                      { return "varint" }
+func (varintCodec) NewReader(r io.Reader) FrameReader { return &varintReader{r: bufio.NewReader(r)} }
+func (varintCodec) NewWriter(w io.Writer) FrameWriter { return &varintWriter{w: w} }
+
+type varintWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (vw *varintWriter) WriteFrame(p []byte) error {
+	if len(p) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	vw.buf = binary.AppendUvarint(vw.buf[:0], uint64(len(p)))
+	vw.buf = append(vw.buf, p...)
+	_, err := vw.w.Write(vw.buf)
+	return err
+}
+
+type varintReader struct {
+	r *bufio.Reader
+}
+
+func (vr *varintReader) ReadFrame() ([]byte, error) {
+	// peek first, so EOF before any header byte is a clean end and not an unexpected one
+	if _, err := vr.r.Peek(1); err != nil {
+		return nil, err
+	}
+	n, err := binary.ReadUvarint(vr.r)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if n > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	return readPayload(vr.r, int(n))
+}
+
+func readPayload(r io.Reader, n int) ([]byte, error) {
+	p := make([]byte, n)
+	if _, err := io.ReadFull(r, p); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF // the header promised n bytes
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// --------------------
+// Newline-delimited JSON
+// --------------------
+
+type ndjsonCodec struct{}
+
+func (ndjsonCodec) Name() string { return "ndjson" }
+func (ndjsonCodec) NewReader(r io.Reader) FrameReader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, MaxFrameSize+1) // +1 for the newline
+	sc.Split(scanLines)
+	return &ndjsonReader{sc: sc}
+}
+
+func (ndjsonCodec) NewWriter(w io.Writer) FrameWriter { return &ndjsonWriter{w: w} }
+
+type ndjsonWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// WriteFrame writes p, which must be valid JSON, compacted onto a single line.
+func (nw *ndjsonWriter) WriteFrame(p []byte) error {
+	nw.buf.Reset()
+	if err := json.Compact(&nw.buf, p); err != nil {
+		return fmt.Errorf("framing: ndjson frame is not valid JSON: %w", err)
+	}
+	if nw.buf.Len() > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	nw.buf.WriteByte('\n') // compacted JSON never contains a raw newline
+	_, err := nw.w.Write(nw.buf.Bytes())
+	return err
+}
+
+type ndjsonReader struct {
+	sc *bufio.Scanner
+}
+
+func (nr *ndjsonReader) ReadFrame() ([]byte, error) {
+	for nr.sc.Scan() {
+		line := bytes.TrimSpace(nr.sc.Bytes())
+		if len(line) == 0 {
+			continue // tolerate blank lines
+		}
+		return bytes.Clone(line), nil
+	}
+	if err := nr.sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, ErrFrameTooLarge
+		}
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// scanLines is bufio.ScanLines, except that a last line without its newline is a torn frame,
+// not a message: the writer always ends a frame with '\n'.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(bytes.TrimSpace(data)) > 0 {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	if atEOF {
+		return len(data), nil, nil // trailing blanks
+	}
+	return 0, nil, nil // need more data
+}
@@ -0,0 +1,137 @@
+package framing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var codecs = []Codec{Length, Varint, NDJSON}
+
+// testFrames returns payloads every codec can carry; the binary codecs get raw bytes as well.
+func testFrames(c Codec) [][]byte {
+	frames := [][]byte{
+		[]byte(`{"id":1,"body":"hello"}`),
+		[]byte(`"a string with a \n newline escape"`),
+		[]byte(`[]`),
+		[]byte(`"` + strings.Repeat("x", 100_000) + `"`), // larger than any bufio buffer
+	}
+	if c != NDJSON {
+		frames = append(frames,
+			[]byte{},                       // empty message
+			[]byte("two\nlines\n"),         // raw newlines
+			[]byte{0, 0xff, '\n', 0, 0x80}, // arbitrary binary
+		)
+	}
+	return frames
+}
+
+func encode(t *testing.T, c Codec, frames [][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	fw := c.NewWriter(&buf)
+	for _, f := range frames {
+		if err := fw.WriteFrame(f); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	readers := map[string]func(io.Reader) io.Reader{
+		"whole":    func(r io.Reader) io.Reader { return r },
+		"one byte": iotest.OneByteReader, // every Read is short
+		"half":     iotest.HalfReader,
+	}
+	for _, c := range codecs {
+		for name, wrap := range readers {
+			t.Run(c.Name()+"/"+name, func(t *testing.T) {
+				frames := testFrames(c)
+				fr := c.NewReader(wrap(bytes.NewReader(encode(t, c, frames))))
+				for i, want := range frames {
+					got, err := fr.ReadFrame()
+					if err != nil {
+						t.Fatalf("frame %d: %v", i, err)
+					}
+					if !bytes.Equal(got, want) {
+						t.Fatalf("frame %d: got %d bytes %.40q, want %d bytes %.40q", i, len(got), got, len(want), want)
+					}
+				}
+				if _, err := fr.ReadFrame(); err != io.EOF {
+					t.Fatalf("after the last frame: got %v, want io.EOF", err)
+				}
+			})
+		}
+	}
+}
+
+// A stream cut anywhere inside a frame is io.ErrUnexpectedEOF, never a shorter message.
+func TestTruncated(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			first := encode(t, c, [][]byte{[]byte(`{"n":1}`)})
+			stream := encode(t, c, [][]byte{[]byte(`{"n":1}`), []byte(`{"n":2,"pad":"xxxxxxxx"}`)})
+			for cut := len(first) + 1; cut < len(stream); cut++ {
+				fr := c.NewReader(bytes.NewReader(stream[:cut]))
+				if _, err := fr.ReadFrame(); err != nil {
+					t.Fatalf("cut at %d: first frame: %v", cut, err)
+				}
+				if got, err := fr.ReadFrame(); err != io.ErrUnexpectedEOF {
+					t.Fatalf("cut at %d: got %q, %v, want io.ErrUnexpectedEOF", cut, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestOversized(t *testing.T) {
+	t.Run("length header", func(t *testing.T) {
+		hdr := binary.BigEndian.AppendUint32(nil, MaxFrameSize+1)
+		if _, err := Length.NewReader(bytes.NewReader(hdr)).ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+			t.Fatalf("got %v, want ErrFrameTooLarge", err)
+		}
+		hdr = binary.BigEndian.AppendUint32(nil, 0xffffffff)
+		if _, err := Length.NewReader(bytes.NewReader(hdr)).ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+			t.Fatalf("max uint32: got %v, want ErrFrameTooLarge", err)
+		}
+	})
+	t.Run("varint header", func(t *testing.T) {
+		hdr := binary.AppendUvarint(nil, 1<<40)
+		if _, err := Varint.NewReader(bytes.NewReader(hdr)).ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+			t.Fatalf("got %v, want ErrFrameTooLarge", err)
+		}
+	})
+	t.Run("ndjson line", func(t *testing.T) {
+		line := `"` + strings.Repeat("x", MaxFrameSize) + `"` + "\n"
+		if _, err := NDJSON.NewReader(strings.NewReader(line)).ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+			t.Fatalf("got %v, want ErrFrameTooLarge", err)
+		}
+	})
+	for _, c := range []Codec{Length, Varint} {
+		t.Run(c.Name()+" writer", func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := c.NewWriter(&buf).WriteFrame(make([]byte, MaxFrameSize+1)); !errors.Is(err, ErrFrameTooLarge) {
+				t.Fatalf("got %v, want ErrFrameTooLarge", err)
+			}
+			if buf.Len() != 0 {
+				t.Fatalf("wrote %d bytes of a rejected frame", buf.Len())
+			}
+		})
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, c := range codecs {
+		if got, err := ByName(c.Name()); err != nil || got != c {
+			t.Fatalf("ByName(%q) = %v, %v", c.Name(), got, err)
+		}
+	}
+	if _, err := ByName("xml"); err == nil {
+		t.Fatal("ByName(\"xml\") should fail")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"pipes/framing"
+)
+
+var (
+	role       = flag.String("role", "parent", "named mode: one of parent, reader, writer, rpc, server, client")
+	path       = flag.String("path", "rubberduck.fifo", "named mode: path to FIFO (named pipe)")
+	codecName  = flag.String("codec", "length", "message framing: one of length, varint, ndjson")
+	clients    = flag.Int("clients", 3, "named rpc role: number of client processes")
+	clientName = flag.String("name", "client", "child roles: name used in messages and output")
+
+	writers = flag.Int("writers", 2, "native processes role: number of writer processes sharing the pipe")
+	stages  = flag.String("stages", "trim,upper,quack", "native pipeline role: comma-separated filters after the source")
+	filter  = flag.String("filter", "", "native filter role: the filter this stage applies")
+
+	fifos       = flag.Int("fifos", 3, "named mux role: number of FIFOs to multiplex")
+	openTimeout = flag.Duration("open-timeout", 3*time.Second, "named mux role: how long to wait for a peer")
+	readTimeout = flag.Duration("read-timeout", 2*time.Second, "named mux role: how long a connected FIFO may stay silent")
+
+	benchN    = flag.Int("n", 100000, "bench mode: number of messages per transport")
+	benchSize = flag.Int("size", 64, "bench mode: message size in bytes (at least 8)")
+	transport = flag.String("transport", "", "bench-sender role: one of pipe, fifo, unix")
+)
+
+// message is the structured message both pipe demos exchange, one per frame.
+type message struct {
+	Seq  int    `json:"seq"`
+	From string `json:"from"`
+	Body string `json:"body"`
+}
+
+// rubberDucks are the message bodies sent by the writers.
+// Some contain newlines and raw bytes, which a newline-terminated line could not carry.
+var rubberDucks = []string{
+	"Rubber duck",
+	"Rubber duck\nwith a newline",
+	"Rubber duck \x00\x01\x02 with binary bytes",
+	"Last rubber duck",
+}
+
+func printAndExit(msg string, code int) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(code)
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . [-codec=name] [-role=role] [-path=fifo] [native|named|bench]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	codec, err := framing.ByName(*codecName)
+	if err != nil {
+		printAndExit(err.Error(), 2)
+	}
+
+	switch mode := flag.Arg(0); mode {
+	case "native":
+		runNative(*role, codec)
+	case "named":
+		runNamed(*role, *path, codec)
+	case "bench":
+		if *role == "bench-sender" {
+			runBenchSender(*transport, *path, *benchN, *benchSize)
+			return
+		}
+		runBench(*benchN, *benchSize)
+	default:
+		printAndExit(fmt.Sprintf("Unknown mode %q. Use 'native', 'named' or 'bench'.", mode), 2)
+	}
+}
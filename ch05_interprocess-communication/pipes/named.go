@@ -0,0 +1,125 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"pipes/framing"
+)
+
+func runNamed(role, path string, codec framing.Codec) {
+	switch role {
+	case "rpc":
+		runRPCParent(path, codec, *clients)
+	case "server":
+		runRPCServer(path, codec)
+	case "client":
+		runRPCClient(path, *clientName, codec)
+	case "mux":
+		runMux(path, *fifos, *openTimeout, *readTimeout)
+	case "mux-writer":
+		runMuxWriter(path, *openTimeout)
+	case "parent":
+		runNamedParent(path, codec)
+	case "reader":
+		runNamedReader(path, codec)
+	case "writer":
+		runNamedWriter(path, codec)
+	default:
+		printAndExit("unknown role: "+role, 2)
+	}
+}
+
+func runNamedParent(path string, codec framing.Codec) {
+	_ = os.Remove(path) // ensure clean state
+
+	// create named pipe (FIFO) with rw-rw-rw- masked by umask
+	if err := syscall.Mkfifo(path, 0666); err != nil {
+		printAndExit("Parent: mkfifo error: "+err.Error(), 1)
+	}
+	defer os.Remove(path) // clean up FIFO on exit
+
+	fmt.Printf("Parent: Created FIFO at %s\n", path)
+
+	// spawn separate tasks that will use the FIFO
+	childArgs := func(role string) []string {
+		return []string{"-role=" + role, "-path=" + path, "-codec=" + codec.Name(), "named"}
+	}
+	readerCmd := exec.Command(os.Args[0], childArgs("reader")...)
+	writerCmd := exec.Command(os.Args[0], childArgs("writer")...)
+
+	readerCmd.Stdout = os.Stdout
+	readerCmd.Stderr = os.Stderr
+	writerCmd.Stdout = os.Stdout
+	writerCmd.Stderr = os.Stderr
+
+	if err := readerCmd.Start(); err != nil {
+		printAndExit("Parent: starting reader error: "+err.Error(), 1)
+	}
+	if err := writerCmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Parent: starting writer error: %v\n", err)
+		_ = readerCmd.Process.Kill()
+		os.Exit(1)
+	}
+
+	// wait for both to finish
+	if err := readerCmd.Wait(); err != nil {
+		fmt.Fprintf(os.Stderr, "Parent: reader error: %v\n", err)
+	}
+	if err := writerCmd.Wait(); err != nil {
+		fmt.Fprintf(os.Stderr, "Parent: writer error: %v\n", err)
+	}
+
+	fmt.Println("Parent: Done.")
+}
+
+func runNamedReader(path string, codec framing.Codec) {
+	// open FIFO for reading, this may block until a writer opens the FIFO
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		printAndExit("Reader: open error: "+err.Error(), 1)
+	}
+	defer f.Close()
+
+	fmt.Println("Reader: Reading...")
+
+	// ReadFrame blocks until a whole frame has arrived, or returns io.EOF once the writer closed
+	fr := codec.NewReader(f)
+	for {
+		var msg message
+		err := framing.ReadJSON(fr, &msg)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			printAndExit("Reader: read error: "+err.Error(), 1)
+		}
+		fmt.Printf("Reader: Received #%d: %q\n", msg.Seq, msg.Body)
+	}
+	fmt.Println("Reader: Writer closed the FIFO.")
+}
+
+func runNamedWriter(path string, codec framing.Codec) {
+	// open FIFO for writing, this may block until a reader opens the FIFO
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		printAndExit("Writer: open error: "+err.Error(), 1)
+	}
+	defer f.Close()
+
+	// FIFO is a byte stream, the codec adds the message framing
+	fw := codec.NewWriter(f)
+	for i, body := range rubberDucks {
+		msg := message{Seq: i + 1, From: "writer", Body: body}
+		fmt.Printf("Writer: Sending %q...\n", msg.Body)
+		if err := framing.WriteJSON(fw, msg); err != nil {
+			printAndExit("Writer: write error: "+err.Error(), 1)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// This file multiplexes several FIFOs on a single thread with epoll, using raw syscalls only.
+// Every FIFO is opened with O_NONBLOCK, so neither open nor read ever parks the thread;
+// epoll_wait is the only place the reader waits, and it waits on all FIFOs at once.
+
+// openFIFOWriter opens a FIFO for writing without blocking forever when no reader shows up.
+//
+// A blocking open(O_WRONLY) waits until some process opens the read end, with no timeout.
+// With O_NONBLOCK the open fails with ENXIO instead, so we retry with backoff until the deadline.
+func openFIFOWriter(path string, timeout time.Duration) (*os.File, error) {
+	deadline := time.Now().Add(timeout)
+	backoff := 10 * time.Millisecond
+	for {
+		fd, err := syscall.Open(path, syscall.O_WRONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+		if err == nil {
+			return os.NewFile(uintptr(fd), path), nil // non-blocking fd: os.File uses the runtime poller
+		}
+		if !errors.Is(err, syscall.ENXIO) {
+			return nil, &os.PathError{Op: "open", Path: path, Err: err}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("no reader opened %s within %s", path, timeout)
+		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, 200*time.Millisecond)
+	}
+}
+
+type muxState int
+
+const (
+	muxWaiting   muxState = iota // open, but no writer has sent anything yet
+	muxConnected                 // data has arrived
+	muxDone                      // writer hung up, or a timeout fired
+)
+
+// muxFIFO is the read side of one FIFO registered with epoll.
+type muxFIFO struct {
+	path     string
+	fd       int
+	state    muxState
+	deadline time.Time // when the current open or read timeout fires
+	buf      []byte    // bytes of an incomplete line
+}
+
+// runMux creates several FIFOs, starts a writer process for all but the last one,
+// and reads all of them from one goroutine with epoll, enforcing open and read timeouts.
+func runMux(path string, n int, openTimeout, readTimeout time.Duration) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		printAndExit("Mux: epoll_create1 error: "+err.Error(), 1)
+	}
+	defer syscall.Close(epfd)
+
+	fifos := make(map[int32]*muxFIFO, n)
+	var writers []*exec.Cmd
+	for i := range n {
+		p := path + "." + strconv.Itoa(i)
+		_ = os.Remove(p)
+		if err := syscall.Mkfifo(p, 0666); err != nil {
+			printAndExit("Mux: mkfifo error: "+err.Error(), 1)
+		}
+		defer os.Remove(p)
+
+		// O_NONBLOCK: returns at once even though no writer exists yet (a blocking open would wait)
+		fd, err := syscall.Open(p, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			printAndExit("Mux: open error: "+err.Error(), 1)
+		}
+		ev := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+		if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &ev); err != nil {
+			printAndExit("Mux: epoll_ctl error: "+err.Error(), 1)
+		}
+		fifos[int32(fd)] = &muxFIFO{path: p, fd: fd, deadline: time.Now().Add(openTimeout)}
+
+		if i == n-1 {
+			fmt.Printf("Mux: %s gets no writer, to show the open timeout\n", p)
+			continue
+		}
+		cmd := exec.Command(os.Args[0], "-role=mux-writer", "-path="+p, "-open-timeout="+openTimeout.String(), "named")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			printAndExit("Mux: starting writer error: "+err.Error(), 1)
+		}
+		writers = append(writers, cmd)
+	}
+
+	finish := func(f *muxFIFO, why string) {
+		if len(f.buf) > 0 {
+			fmt.Printf("Mux: %s: partial line at close: %q\n", f.path, f.buf)
+		}
+		fmt.Printf("Mux: %s: %s\n", f.path, why)
+		_ = syscall.EpollCtl(epfd, syscall.EPOLL_CTL_DEL, f.fd, nil)
+		syscall.Close(f.fd)
+		f.state = muxDone
+	}
+
+	events := make([]syscall.EpollEvent, n)
+	chunk := make([]byte, 4096)
+	for open := n; open > 0; {
+		// sleep until the nearest timeout, unless a FIFO becomes readable first
+		next := time.Time{}
+		for _, f := range fifos {
+			if f.state != muxDone && (next.IsZero() || f.deadline.Before(next)) {
+				next = f.deadline
+			}
+		}
+		wait := max(time.Until(next), 0)
+
+		nev, err := syscall.EpollWait(epfd, events, int(wait.Milliseconds())+1)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			printAndExit("Mux: epoll_wait error: "+err.Error(), 1)
+		}
+
+		for _, ev := range events[:nev] {
+			f := fifos[ev.Fd]
+			if f.state == muxDone {
+				continue
+			}
+			// drain everything available: with O_NONBLOCK, read returns EAGAIN instead of waiting
+			for {
+				k, err := syscall.Read(f.fd, chunk)
+				if k > 0 {
+					f.state = muxConnected
+					f.deadline = time.Now().Add(readTimeout)
+					f.buf = append(f.buf, chunk[:k]...)
+					for {
+						i := bytes.IndexByte(f.buf, '\n')
+						if i < 0 {
+							break
+						}
+						fmt.Printf("Mux: %s: received %q\n", f.path, f.buf[:i])
+						f.buf = f.buf[i+1:]
+					}
+					continue
+				}
+				if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+					break
+				}
+				if err != nil {
+					finish(f, "read error: "+err.Error())
+				} else {
+					finish(f, "writer closed (EOF)") // k == 0: EPOLLHUP, every writer is gone
+				}
+				open--
+				break
+			}
+		}
+
+		now := time.Now()
+		for _, f := range fifos {
+			if f.state == muxDone || now.Before(f.deadline) {
+				continue
+			}
+			if f.state == muxWaiting {
+				finish(f, fmt.Sprintf("error: no writer sent anything within %s", openTimeout))
+			} else {
+				finish(f, fmt.Sprintf("error: read timeout, no data for %s", readTimeout))
+			}
+			open--
+		}
+	}
+
+	for _, cmd := range writers {
+		if err := cmd.Wait(); err != nil {
+			fmt.Fprintf(os.Stderr, "Mux: writer error: %v\n", err)
+		}
+	}
+	fmt.Println("Mux: Done.")
+}
+
+// runMuxWriter sends a few lines at its own pace, so the FIFOs become readable at different times.
+func runMuxWriter(path string, openTimeout time.Duration) {
+	f, err := openFIFOWriter(path, openTimeout)
+	if err != nil {
+		printAndExit("Writer: "+err.Error(), 1)
+	}
+	defer f.Close()
+
+	pace := time.Duration(100+os.Getpid()%400) * time.Millisecond
+	for i := range 3 {
+		time.Sleep(pace)
+		if err := f.SetWriteDeadline(time.Now().Add(time.Second)); err != nil {
+			printAndExit("Writer: "+err.Error(), 1)
+		}
+		if _, err := fmt.Fprintf(f, "Rubber duck %d from pid %d\n", i+1, os.Getpid()); err != nil {
+			printAndExit("Writer: write error: "+err.Error(), 1)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "time"
+
+func runMux(path string, n int, openTimeout, readTimeout time.Duration) {
+	printAndExit("the epoll multiplexing example is only available on Linux", 1)
+}
+
+func runMuxWriter(path string, openTimeout time.Duration) {
+	printAndExit("the epoll multiplexing example is only available on Linux", 1)
+}
@@ -0,0 +1,302 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"pipes/framing"
+)
+
+// pipeBuf is the POSIX minimum for PIPE_BUF (Linux uses 4096, macOS 512).
+// Writes of at most PIPE_BUF bytes to a pipe or FIFO are atomic,
+// so frames from concurrent clients never interleave on the request FIFO.
+const pipeBuf = 512
+
+// request is sent by a client on the shared, well-known request FIFO.
+type request struct {
+	ID      uint64 `json:"id"`       // unique per client, echoed in the response
+	Client  string `json:"client"`   // for logging
+	ReplyTo string `json:"reply_to"` // path of the client's own reply FIFO
+	Op      string `json:"op"`       // "quack", "bye" (client leaves) or "shutdown" (server exits)
+	Body    string `json:"body,omitempty"`
+}
+
+// response is sent by the server on the reply FIFO of the client that made the request.
+type response struct {
+	ID    uint64 `json:"id"`
+	Body  string `json:"body,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// atomicWriter rejects writes larger than pipeBuf instead of letting the kernel split them,
+// which could interleave a large frame with frames from other processes.
+type atomicWriter struct{ w io.Writer }
+
+func (aw atomicWriter) Write(p []byte) (int, error) {
+	if len(p) > pipeBuf {
+		return 0, fmt.Errorf("frame of %d bytes exceeds PIPE_BUF (%d), write would not be atomic", len(p), pipeBuf)
+	}
+	return aw.w.Write(p)
+}
+
+// runRPCParent creates the request FIFO, then starts one server and several client processes.
+func runRPCParent(path string, codec framing.Codec, clients int) {
+	_ = os.Remove(path)
+	if err := syscall.Mkfifo(path, 0666); err != nil {
+		printAndExit("Parent: mkfifo error: "+err.Error(), 1)
+	}
+	defer os.Remove(path)
+	fmt.Printf("Parent: Created request FIFO at %s\n", path)
+
+	start := func(role string, extra ...string) *exec.Cmd {
+		args := append([]string{"-role=" + role, "-path=" + path, "-codec=" + codec.Name()}, extra...)
+		cmd := exec.Command(os.Args[0], append(args, "named")...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			printAndExit("Parent: starting "+role+" error: "+err.Error(), 1)
+		}
+		return cmd
+	}
+
+	// the clients start first on purpose: they block in open until the server opens the read end
+	var clientCmds []*exec.Cmd
+	for i := range clients {
+		clientCmds = append(clientCmds, start("client", "-name=client-"+strconv.Itoa(i+1)))
+	}
+	server := start("server")
+
+	for _, cmd := range clientCmds {
+		if err := cmd.Wait(); err != nil {
+			fmt.Fprintf(os.Stderr, "Parent: client error: %v\n", err)
+		}
+	}
+
+	// every client is done: ask the server to exit through the same request FIFO
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		printAndExit("Parent: open error: "+err.Error(), 1)
+	}
+	err = framing.WriteJSON(codec.NewWriter(atomicWriter{f}), request{Client: "parent", Op: "shutdown"})
+	f.Close()
+	if err != nil {
+		printAndExit("Parent: write error: "+err.Error(), 1)
+	}
+
+	if err := server.Wait(); err != nil {
+		fmt.Fprintf(os.Stderr, "Parent: server error: %v\n", err)
+	}
+	fmt.Println("Parent: Done.")
+}
+
+// replyConn is the server's write end of one client's reply FIFO.
+type replyConn struct {
+	mu sync.Mutex // responses are written by concurrent handlers
+	f  *os.File
+	fw framing.FrameWriter
+}
+
+// rpcServer serves requests from the request FIFO, each in its own goroutine.
+type rpcServer struct {
+	codec framing.Codec
+
+	mu      sync.Mutex
+	replies map[string]*replyConn // reply FIFO path -> open write end
+}
+
+func runRPCServer(path string, codec framing.Codec) {
+	// Opening a FIFO read-only blocks until a writer shows up, and once the last writer closes,
+	// reads return EOF. A server must outlive its clients, so it opens the read end without blocking,
+	// then holds a write end of its own: the FIFO always has a writer and never hits EOF between clients.
+	rf, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		printAndExit("Server: open error: "+err.Error(), 1)
+	}
+	defer rf.Close()
+	keepAlive, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		printAndExit("Server: open keep-alive writer error: "+err.Error(), 1)
+	}
+	defer keepAlive.Close()
+
+	fmt.Println("Server: Listening for requests...")
+
+	s := &rpcServer{codec: codec, replies: make(map[string]*replyConn)}
+	var wg sync.WaitGroup
+	fr := codec.NewReader(rf)
+	for {
+		var req request
+		if err := framing.ReadJSON(fr, &req); err != nil {
+			printAndExit("Server: read error: "+err.Error(), 1)
+		}
+
+		switch req.Op {
+		case "shutdown":
+			wg.Wait() // let in-flight requests finish answering
+			s.closeAll()
+			fmt.Println("Server: Shutting down.")
+			return
+		case "bye":
+			// the client only says bye after it received all its responses
+			s.closeReply(req.ReplyTo)
+			fmt.Printf("Server: %s left\n", req.Client)
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.handle(req)
+			}()
+		}
+	}
+}
+
+func (s *rpcServer) handle(req request) {
+	time.Sleep(time.Duration(rand.Intn(300)) * time.Millisecond) // simulate uneven work
+
+	resp := response{ID: req.ID}
+	switch req.Op {
+	case "quack":
+		resp.Body = strings.ToUpper(req.Body) + "! QUACK!"
+	default:
+		resp.Error = "unknown op " + strconv.Quote(req.Op)
+	}
+
+	if err := s.reply(req.ReplyTo, resp); err != nil {
+		// the client is gone; there is nobody to tell, so log and move on
+		fmt.Fprintf(os.Stderr, "Server: dropping response %d for %s: %v\n", req.ID, req.Client, err)
+	}
+}
+
+// reply writes resp to the client's reply FIFO, opening it on first use.
+func (s *rpcServer) reply(path string, resp response) error {
+	rc, err := s.replyConn(path)
+	if err != nil {
+		return err
+	}
+
+	rc.mu.Lock()
+	err = framing.WriteJSON(rc.fw, resp)
+	rc.mu.Unlock()
+
+	if errors.Is(err, syscall.EPIPE) {
+		// the client closed its read end (or died) since we opened the FIFO
+		s.closeReply(path)
+		return fmt.Errorf("client hung up: %w", err)
+	}
+	return err
+}
+
+func (s *rpcServer) replyConn(path string) (*replyConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rc, ok := s.replies[path]; ok {
+		return rc, nil
+	}
+
+	// A blocking open for writing would wait forever if the client already died.
+	// With O_NONBLOCK it fails immediately with ENXIO when nobody has the read end open.
+	f, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if errors.Is(err, syscall.ENXIO) {
+		return nil, fmt.Errorf("no reader on %s: %w", path, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rc := &replyConn{f: f, fw: s.codec.NewWriter(atomicWriter{f})}
+	s.replies[path] = rc
+	return rc, nil
+}
+
+func (s *rpcServer) closeReply(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rc, ok := s.replies[path]; ok {
+		rc.f.Close()
+		delete(s.replies, path)
+	}
+}
+
+func (s *rpcServer) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path, rc := range s.replies {
+		rc.f.Close()
+		delete(s.replies, path)
+	}
+}
+
+// runRPCClient sends a batch of requests without waiting, then matches the responses by ID,
+// since the server answers them concurrently and in no particular order.
+func runRPCClient(path, name string, codec framing.Codec) {
+	replyPath := fmt.Sprintf("%s.%d.reply", path, os.Getpid())
+	_ = os.Remove(replyPath)
+	if err := syscall.Mkfifo(replyPath, 0666); err != nil {
+		printAndExit(name+": mkfifo error: "+err.Error(), 1)
+	}
+	defer os.Remove(replyPath)
+
+	// Same trick as the server: open the read end first without blocking, so the server's
+	// non-blocking open for writing finds a reader, and hold a write end so reads never see EOF
+	// just because the server has not opened its end yet.
+	rf, err := os.OpenFile(replyPath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		printAndExit(name+": open reply FIFO error: "+err.Error(), 1)
+	}
+	defer rf.Close()
+	keepAlive, err := os.OpenFile(replyPath, os.O_WRONLY, 0)
+	if err != nil {
+		printAndExit(name+": open keep-alive writer error: "+err.Error(), 1)
+	}
+	defer keepAlive.Close()
+
+	// this blocks until the server has opened the request FIFO for reading
+	wf, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		printAndExit(name+": open request FIFO error: "+err.Error(), 1)
+	}
+	defer wf.Close()
+	fw := codec.NewWriter(atomicWriter{wf})
+
+	pending := make(map[uint64]string)
+	for i, body := range rubberDucks[:3] {
+		req := request{ID: uint64(i + 1), Client: name, ReplyTo: replyPath, Op: "quack", Body: body}
+		if err := framing.WriteJSON(fw, req); err != nil {
+			printAndExit(name+": write error: "+err.Error(), 1)
+		}
+		pending[req.ID] = body
+		fmt.Printf("%s: Sent request %d: %q\n", name, req.ID, body)
+	}
+
+	fr := codec.NewReader(rf)
+	for len(pending) > 0 {
+		var resp response
+		if err := framing.ReadJSON(fr, &resp); err != nil {
+			printAndExit(name+": read error: "+err.Error(), 1)
+		}
+		if _, ok := pending[resp.ID]; !ok {
+			fmt.Fprintf(os.Stderr, "%s: unexpected response id %d\n", name, resp.ID)
+			continue
+		}
+		delete(pending, resp.ID)
+		if resp.Error != "" {
+			fmt.Printf("%s: Request %d failed: %s\n", name, resp.ID, resp.Error)
+			continue
+		}
+		fmt.Printf("%s: Response to %d: %q\n", name, resp.ID, resp.Body)
+	}
+
+	if err := framing.WriteJSON(fw, request{Client: name, ReplyTo: replyPath, Op: "bye"}); err != nil {
+		printAndExit(name+": write error: "+err.Error(), 1)
+	}
+}
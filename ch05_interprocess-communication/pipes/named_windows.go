@@ -0,0 +1,7 @@
+package main
+
+import "pipes/framing"
+
+func runNamed(role, path string, codec framing.Codec) {
+	printAndExit("named pipes are not supported on Windows in this example", 1)
+}
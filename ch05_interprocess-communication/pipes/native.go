@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"pipes/framing"
+)
+
+func runNativeWriter(w *os.File, codec framing.Codec, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer w.Close() // signal EOF to reader after data is consumed
+
+	fw := codec.NewWriter(w)
+	for i, body := range rubberDucks {
+		msg := message{Seq: i + 1, From: "writer", Body: body}
+		fmt.Printf("Writer: Sending %q...\n", msg.Body)
+		if err := framing.WriteJSON(fw, msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Writer: write error: %v\n", err)
+			return
+		}
+	}
+}
+
+func runNativeReader(r *os.File, codec framing.Codec, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer r.Close()
+
+	fmt.Println("Reader: Reading...")
+
+	// ReadFrame blocks until a whole frame has arrived, or returns io.EOF once the writer closed
+	fr := codec.NewReader(r)
+	for {
+		var msg message
+		err := framing.ReadJSON(fr, &msg)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Reader: read error: %v\n", err)
+			return
+		}
+		fmt.Printf("Reader: Received #%d: %q\n", msg.Seq, msg.Body)
+	}
+	fmt.Println("Reader: Writer closed the pipe.")
+}
+
+func runNative(role string, codec framing.Codec) {
+	switch role {
+	case "parent":
+		runNativeThreads(codec)
+	case "processes":
+		runNativeProcesses(codec, *writers)
+	case "pipeline":
+		runNativePipeline(codec, strings.Split(*stages, ","))
+	case "child-writer":
+		runNativeChildWriter(codec, *clientName)
+	case "source":
+		runNativeSource(codec)
+	case "filter":
+		runNativeFilter(codec, *filter)
+	default:
+		printAndExit("unknown role: "+role, 2)
+	}
+}
+
+// runNativeThreads passes both ends of the pipe to goroutines of the same process.
+func runNativeThreads(codec framing.Codec) {
+	// os.Pipe returns a connected pair of Files; reads from r return bytes written to w
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Main: pipe error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go runNativeWriter(w, codec, &wg)
+	go runNativeReader(r, codec, &wg)
+	wg.Wait() // block main until child threads finish, python is implicit on this
+}
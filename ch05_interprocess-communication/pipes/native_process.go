@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"pipes/framing"
+)
+
+// pipeFilters are the stages available to the native pipeline, each transforming one message.
+var pipeFilters = map[string]func(message) message{
+	"upper": func(m message) message { m.Body = strings.ToUpper(m.Body); return m },
+	"quack": func(m message) message { m.Body += ", quack!"; return m },
+	"trim":  func(m message) message { m.Body = strings.Join(strings.Fields(m.Body), " "); return m },
+}
+
+// nativeChild re-executes this program in a child role of the native mode.
+func nativeChild(codec framing.Codec, role string, extra ...string) *exec.Cmd {
+	args := append([]string{"-role=" + role, "-codec=" + codec.Name()}, extra...)
+	cmd := exec.Command(os.Args[0], append(args, "native")...)
+	cmd.Stderr = os.Stderr // stdout may be a pipe, so children log to stderr
+	return cmd
+}
+
+// runNativeProcesses hands the write end of one pipe to several child processes.
+// The parent reads until EOF, which only arrives once every copy of the write end is closed:
+// both children must exit, and the parent must close its own copy right after starting them.
+func runNativeProcesses(codec framing.Codec, writers int) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		printAndExit("Parent: pipe error: "+err.Error(), 1)
+	}
+
+	var cmds []*exec.Cmd
+	for i := range writers {
+		cmd := nativeChild(codec, "child-writer", fmt.Sprintf("-name=child-%d", i+1))
+		cmd.Stdout = os.Stdout
+		cmd.ExtraFiles = []*os.File{w} // the write end becomes fd 3 in the child
+		if err := cmd.Start(); err != nil {
+			printAndExit("Parent: starting writer error: "+err.Error(), 1)
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	// without this, the parent itself would be a writer forever and the read loop would never end
+	w.Close()
+	fmt.Printf("Parent: started %d writer processes, closed our write end, reading...\n", writers)
+
+	fr := codec.NewReader(r)
+	for {
+		var msg message
+		err := framing.ReadJSON(fr, &msg)
+		if errors.Is(err, io.EOF) {
+			fmt.Println("Parent: EOF, every writer has closed the pipe.")
+			break
+		}
+		if err != nil {
+			printAndExit("Parent: read error: "+err.Error(), 1)
+		}
+		fmt.Printf("Parent: Received #%d from %s: %q\n", msg.Seq, msg.From, msg.Body)
+	}
+	r.Close()
+
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			fmt.Fprintf(os.Stderr, "Parent: writer error: %v\n", err)
+		}
+	}
+}
+
+// runNativeChildWriter is the child side of runNativeProcesses, writing to the inherited fd 3.
+func runNativeChildWriter(codec framing.Codec, name string) {
+	w := os.NewFile(3, "pipe")
+	defer w.Close() // exiting would close it too; either way this writer is gone
+
+	fw := codec.NewWriter(w)
+	for i, body := range rubberDucks {
+		msg := message{Seq: i + 1, From: name, Body: body}
+		if err := framing.WriteJSON(fw, msg); err != nil {
+			printAndExit(name+": write error: "+err.Error(), 1)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s: sent %d messages, exiting\n", name, len(rubberDucks))
+}
+
+// runNativePipeline wires child processes the way a shell runs `source | f1 | f2 | ...`:
+// each stage's stdout is the write end of a pipe whose read end is the next stage's stdin.
+// When a stage exits, its stdout closes, the next stage sees EOF and exits too, down to the parent.
+func runNativePipeline(codec framing.Codec, stages []string) {
+	for _, s := range stages {
+		if _, ok := pipeFilters[s]; !ok {
+			printAndExit("Parent: unknown filter "+s, 2)
+		}
+	}
+
+	cmds := []*exec.Cmd{nativeChild(codec, "source")}
+	for _, s := range stages {
+		cmds = append(cmds, nativeChild(codec, "filter", "-filter="+s))
+	}
+	fmt.Printf("Parent: running source | %s\n", strings.Join(stages, " | "))
+
+	done := make(chan struct{}) // closed once the parent has read the last stage's output
+
+	// connect stage i's stdout to stage i+1's stdin, the last stage writes to a pipe we read
+	var closeAfterStart []*os.File
+	for i, cmd := range cmds {
+		r, w, err := os.Pipe()
+		if err != nil {
+			printAndExit("Parent: pipe error: "+err.Error(), 1)
+		}
+		cmd.Stdout = w
+		closeAfterStart = append(closeAfterStart, w)
+		if i+1 < len(cmds) {
+			cmds[i+1].Stdin = r
+			closeAfterStart = append(closeAfterStart, r)
+		} else {
+			defer r.Close()
+			go func() {
+				readPipelineOutput(codec, r)
+				close(done)
+			}()
+		}
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Start(); err != nil {
+			printAndExit("Parent: starting stage error: "+err.Error(), 1)
+		}
+	}
+	// the children hold their own copies now; ours would keep every pipe open forever
+	for _, f := range closeAfterStart {
+		f.Close()
+	}
+
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			fmt.Fprintf(os.Stderr, "Parent: stage error: %v\n", err)
+		}
+	}
+	<-done
+	fmt.Println("Parent: Done.")
+}
+
+func readPipelineOutput(codec framing.Codec, r io.Reader) {
+	fr := codec.NewReader(r)
+	for {
+		var msg message
+		err := framing.ReadJSON(fr, &msg)
+		if errors.Is(err, io.EOF) {
+			fmt.Println("Parent: EOF from the last stage.")
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Parent: read error: %v\n", err)
+			return
+		}
+		fmt.Printf("Parent: Received #%d: %q\n", msg.Seq, msg.Body)
+	}
+}
+
+// runNativeSource is the first pipeline stage: it writes the messages to stdout.
+func runNativeSource(codec framing.Codec) {
+	fw := codec.NewWriter(os.Stdout)
+	for i, body := range rubberDucks {
+		if err := framing.WriteJSON(fw, message{Seq: i + 1, From: "source", Body: body}); err != nil {
+			printAndExit("source: write error: "+err.Error(), 1)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "source: done, closing stdout")
+}
+
+// runNativeFilter is a middle pipeline stage: stdin to stdout, one message at a time, until EOF.
+func runNativeFilter(codec framing.Codec, name string) {
+	f := pipeFilters[name]
+	fr := codec.NewReader(os.Stdin)
+	fw := codec.NewWriter(os.Stdout)
+	for {
+		var msg message
+		err := framing.ReadJSON(fr, &msg)
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintf(os.Stderr, "%s: EOF on stdin, closing stdout\n", name)
+			return
+		}
+		if err != nil {
+			printAndExit(name+": read error: "+err.Error(), 1)
+		}
+		if err := framing.WriteJSON(fw, f(msg)); err != nil {
+			printAndExit(name+": write error: "+err.Error(), 1)
+		}
+	}
+}
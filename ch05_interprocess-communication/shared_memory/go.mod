@@ -0,0 +1,3 @@
+module sharedmem
+
+go 1.25.5
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	SIZE  = 4  // ring buffer capacity, a power of two
+	ITEMS = 10 // values sent through it, more than fit at once
+)
+
+// runProducer pushes ITEMS values, waiting whenever the consumer has not yet freed a slot.
+func runProducer(name string, r *ring) {
+	for i := range ITEMS {
+		if !r.TryPush(int64(i)) {
+			fmt.Printf("%s: Buffer full, waiting\n", name)
+			r.Push(int64(i))
+		}
+		fmt.Printf("%s: Wrote: %d\n", name, i)
+	}
+}
+
+// runConsumer pops ITEMS values, waiting whenever the producer has not yet published one.
+func runConsumer(name string, r *ring) {
+	for range ITEMS {
+		v, ok := r.TryPop()
+		if !ok {
+			fmt.Printf("%s: Data not available, waiting\n", name)
+			v = r.Pop()
+		}
+		fmt.Printf("%s: Read: %d\n", name, v)
+	}
+}
+
+// runThreads shares a ring buffer in ordinary memory between two goroutines of one process.
+func runThreads() {
+	r := newLocalRing(SIZE)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() { defer wg.Done(); runConsumer("Consumer", r) }()
+	go func() { defer wg.Done(); runProducer("Producer", r) }()
+
+	wg.Wait() // prevent main from exiting before goroutines finish
+}
+
+func main() {
+	role := flag.String("role", "producer", "process mode: producer or consumer (the consumer is started by the producer)")
+	shmPath := flag.String("shm", "", "process mode: map this file instead of an anonymous memfd, e.g. /dev/shm/shared_ipc")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . [-shm=path] [threads|process]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	mode := flag.Arg(0)
+	if mode == "" {
+		mode = "threads"
+	}
+
+	switch mode {
+	case "threads":
+		runThreads()
+	case "process":
+		if *role == "consumer" {
+			runProcessConsumer(*shmPath)
+			return
+		}
+		runProcessProducer(*shmPath)
+	default:
+		fmt.Printf("Unknown mode %q. Use 'threads' or 'process'.\n", mode)
+		os.Exit(1)
+	}
+}
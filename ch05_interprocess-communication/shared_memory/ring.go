@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// ring is a single-producer/single-consumer lock-free ring buffer of int64 values.
+// It lives in a plain block of memory, so the same code works on a Go allocation shared by
+// two goroutines and on an mmap'd region shared by two processes. Layout:
+//
+//	offset   0: tail, the number of values ever pushed (written by the producer only)
+//	offset   4: tail waiters, set while the consumer sleeps on tail
+//	offset  64: head, the number of values ever popped (written by the consumer only)
+//	offset  68: head waiters, set while the producer sleeps on head
+//	offset 128: capacity int64 slots
+//
+// head and tail sit on separate cache lines, so the two sides do not invalidate each other's line
+// on every update (false sharing). Both indices run freely and wrap around at 2^32; tail-head is
+// the number of values in the buffer and index&mask the slot, which is why capacity must be a power of two.
+//
+// Memory ordering: the producer writes the slot, then stores tail; the consumer loads tail, then
+// reads the slot. The atomic store/load pair orders the plain slot access between the two sides
+// (Go atomics are sequentially consistent, stronger than the release/acquire this needs).
+// The same holds in the other direction for head, so the producer never overwrites an unread slot.
+//
+// TryPush and TryPop stay off the kernel: they only issue a futex wake when the other side
+// has announced in the waiters word that it is about to sleep.
+type ring struct {
+	tail        *atomic.Uint32
+	tailWaiters *atomic.Uint32
+	head        *atomic.Uint32
+	headWaiters *atomic.Uint32
+	slots       []int64
+	mask        uint32
+}
+
+const (
+	cacheLine   = 64
+	ringHeader  = 2 * cacheLine
+	ringSlotLen = 8
+)
+
+// ringSize is the number of bytes a ring of the given capacity needs.
+func ringSize(capacity int) int { return ringHeader + capacity*ringSlotLen }
+
+// newRing lays a ring over mem, which must be zeroed (or hold a ring of the same capacity),
+// 8-byte aligned and at least ringSize(capacity) bytes long.
+func newRing(mem []byte, capacity int) *ring {
+	if capacity <= 0 || capacity&(capacity-1) != 0 {
+		panic("ring: capacity must be a power of two")
+	}
+	if len(mem) < ringSize(capacity) {
+		panic("ring: memory too small")
+	}
+	return &ring{
+		tail:        (*atomic.Uint32)(unsafe.Pointer(&mem[0])),
+		tailWaiters: (*atomic.Uint32)(unsafe.Pointer(&mem[4])),
+		head:        (*atomic.Uint32)(unsafe.Pointer(&mem[cacheLine])),
+		headWaiters: (*atomic.Uint32)(unsafe.Pointer(&mem[cacheLine+4])),
+		slots:       unsafe.Slice((*int64)(unsafe.Pointer(&mem[ringHeader])), capacity),
+		mask:        uint32(capacity - 1),
+	}
+}
+
+// newLocalRing allocates a ring in ordinary Go memory, for use between goroutines.
+func newLocalRing(capacity int) *ring {
+	words := make([]uint64, ringSize(capacity)/8) // a []uint64 is 8-byte aligned, a []byte need not be
+	return newRing(unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), len(words)*8), capacity)
+}
+
+// TryPush adds v unless the buffer is full. Only the producer may call it.
+func (r *ring) TryPush(v int64) bool {
+	tail := r.tail.Load() // only we write tail, the load cannot race
+	if tail-r.head.Load() > r.mask {
+		return false // full: the consumer has not freed a slot yet
+	}
+	r.slots[tail&r.mask] = v
+	r.tail.Store(tail + 1) // publish: the slot write above happens before this store
+	if r.tailWaiters.Load() != 0 {
+		futexWakeAll(r.tail)
+	}
+	return true
+}
+
+// TryPop removes the oldest value unless the buffer is empty. Only the consumer may call it.
+func (r *ring) TryPop() (int64, bool) {
+	head := r.head.Load()
+	if r.tail.Load() == head {
+		return 0, false // empty
+	}
+	v := r.slots[head&r.mask]
+	r.head.Store(head + 1) // free the slot: the read above happens before the producer reuses it
+	if r.headWaiters.Load() != 0 {
+		futexWakeAll(r.head)
+	}
+	return v, true
+}
+
+// Push adds v, sleeping while the buffer is full.
+func (r *ring) Push(v int64) {
+	for !r.TryPush(v) {
+		sleepWhile(r.head, r.headWaiters, r.tail.Load()-r.mask-1)
+	}
+}
+
+// Pop removes the oldest value, sleeping while the buffer is empty.
+func (r *ring) Pop() int64 {
+	for {
+		if v, ok := r.TryPop(); ok {
+			return v
+		}
+		sleepWhile(r.tail, r.tailWaiters, r.head.Load())
+	}
+}
+
+// sleepWhile sleeps while *addr == val, announcing itself in waiters first.
+// Either the other side's store to addr comes first, and the re-check in the futex sees it,
+// or our waiters store comes first, and the other side sees it and wakes us:
+// with sequentially consistent atomics, a wakeup cannot fall in between and get lost.
+func sleepWhile(addr, waiters *atomic.Uint32, val uint32) {
+	waiters.Store(1)
+	futexWaitOn(addr, val) // returns at once if addr already moved
+	waiters.Store(0)
+}
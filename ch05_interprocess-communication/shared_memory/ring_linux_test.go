@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// mapTestRing creates a memfd holding a ring and maps it, returning the file and the ring over the mapping.
+func mapTestRing(t *testing.T, capacity int) (*os.File, *ring) {
+	t.Helper()
+	f, err := memfdCreate("ring_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	if err := f.Truncate(int64(ringSize(capacity))); err != nil {
+		t.Fatal(err)
+	}
+	return f, mapTestFile(t, f, capacity)
+}
+
+func mapTestFile(t *testing.T, f *os.File, capacity int) *ring {
+	t.Helper()
+	mem, err := mapShared(f, ringSize(capacity))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Munmap(mem) })
+	return newRing(mem, capacity)
+}
+
+// Producer and consumer use two separate mappings of the same pages, as two processes would.
+func TestRingSPSCMmap(t *testing.T) {
+	f, producer := mapTestRing(t, SIZE)
+	consumer := mapTestFile(t, f, SIZE)
+	stressRing(t, producer, consumer, 100_000)
+}
+
+const (
+	helperEnv = "RING_TEST_CONSUMER" // set to the number of values to consume
+	helperN   = 100_000
+)
+
+// TestRingSPSCProcess runs the consumer in a child process, the way the process mode does.
+func TestRingSPSCProcess(t *testing.T) {
+	f, producer := mapTestRing(t, SIZE)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRingConsumerProcess$")
+	cmd.Env = append(os.Environ(), helperEnv+"="+strconv.Itoa(helperN))
+	cmd.ExtraFiles = []*os.File{f} // fd 3 in the child
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range helperN {
+		producer.Push(int64(i))
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("consumer process: %v", err)
+	}
+}
+
+// TestRingConsumerProcess is the child side of TestRingSPSCProcess; on its own it does nothing.
+func TestRingConsumerProcess(t *testing.T) {
+	n, err := strconv.Atoi(os.Getenv(helperEnv))
+	if err != nil {
+		t.Skip("only runs as the child of TestRingSPSCProcess")
+	}
+	r := mapTestFile(t, os.NewFile(3, "memfd:ring_test"), SIZE)
+	for want := range int64(n) {
+		if v := r.Pop(); v != want {
+			t.Fatalf("Pop = %d, want %d", v, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRingEmptyAndFull(t *testing.T) {
+	r := newLocalRing(4)
+	if _, ok := r.TryPop(); ok {
+		t.Fatal("TryPop on an empty ring succeeded")
+	}
+	for i := range 4 {
+		if !r.TryPush(int64(i)) {
+			t.Fatalf("TryPush %d failed before the ring was full", i)
+		}
+	}
+	if r.TryPush(4) {
+		t.Fatal("TryPush on a full ring succeeded")
+	}
+	if v, ok := r.TryPop(); !ok || v != 0 {
+		t.Fatalf("TryPop = %d, %v, want 0, true", v, ok)
+	}
+	if !r.TryPush(4) {
+		t.Fatal("TryPush failed after a slot was freed")
+	}
+	for want := int64(1); want <= 4; want++ {
+		if v, ok := r.TryPop(); !ok || v != want {
+			t.Fatalf("TryPop = %d, %v, want %d, true", v, ok, want)
+		}
+	}
+	if _, ok := r.TryPop(); ok {
+		t.Fatal("TryPop on a drained ring succeeded")
+	}
+}
+
+// The slots wrap around every capacity values, and the indices themselves wrap at 2^32.
+func TestRingWraparound(t *testing.T) {
+	for _, start := range []uint32{0, 1<<32 - 5} {
+		r := newLocalRing(4)
+		r.tail.Store(start) // as if start values had already gone through
+		r.head.Store(start)
+		next, want := int64(0), int64(0)
+		for round := range 10 {
+			for range round%4 + 1 { // vary the fill level, so every slot takes a turn at every position
+				if !r.TryPush(next) {
+					t.Fatalf("start %d: TryPush %d failed", start, next)
+				}
+				next++
+			}
+			for range round%4 + 1 {
+				v, ok := r.TryPop()
+				if !ok || v != want {
+					t.Fatalf("start %d: TryPop = %d, %v, want %d, true", start, v, ok, want)
+				}
+				want++
+			}
+		}
+		if start > 0 && r.tail.Load() >= start {
+			t.Fatalf("start %d: tail %d did not wrap around", start, r.tail.Load())
+		}
+	}
+}
+
+func TestRingCapacityPowerOfTwo(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("newLocalRing(3) did not panic")
+		}
+	}()
+	newLocalRing(3)
+}
+
+// stressRing streams n values from a producer to a consumer through the two views of one ring,
+// with blocking Push and Pop, and checks that every value arrives once and in order.
+func stressRing(t *testing.T, producer, consumer *ring, n int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range n {
+			producer.Push(int64(i))
+		}
+	}()
+	for want := range int64(n) {
+		if v := consumer.Pop(); v != want {
+			t.Fatalf("Pop = %d, want %d", v, want)
+		}
+	}
+	wg.Wait()
+	if _, ok := consumer.TryPop(); ok {
+		t.Fatal("a value was left over")
+	}
+}
+
+func TestRingSPSCGoroutines(t *testing.T) {
+	r := newLocalRing(SIZE) // small, so both sides keep blocking on each other
+	stressRing(t, r, r, 100_000)
+}
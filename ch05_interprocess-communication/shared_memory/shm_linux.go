@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// sysMemfdCreate is the memfd_create syscall number; the syscall package only defines it on some architectures.
+var sysMemfdCreate = map[string]uintptr{
+	"386": 356, "amd64": 319, "arm": 385, "arm64": 279, "loong64": 279, "riscv64": 279,
+}[runtime.GOARCH]
+
+// memfdCreate returns an anonymous, memory-backed file: shared memory without a name in the file system.
+// The only way for another process to reach it is to inherit the file descriptor.
+func memfdCreate(name string) (*os.File, error) {
+	if sysMemfdCreate == 0 {
+		return nil, fmt.Errorf("memfd_create: unknown syscall number on %s", runtime.GOARCH)
+	}
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(p)), 0, 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("memfd_create", errno)
+	}
+	return os.NewFile(fd, "memfd:"+name), nil
+}
+
+// mapShared maps size bytes of f with MAP_SHARED: stores by any process mapping the same file
+// are visible to all of them, because they all map the very same physical pages.
+func mapShared(f *os.File, size int) ([]byte, error) {
+	mem, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	return mem, nil
+}
+
+const (
+	futexWait = 0 // FUTEX_WAIT, without FUTEX_PRIVATE_FLAG so it works across processes
+	futexWake = 1 // FUTEX_WAKE
+)
+
+// futexWaitOn sleeps in the kernel while *addr == val. It may return spuriously, callers re-check.
+// The ring buffer waits on its head and tail words this way, in one process or across several.
+func futexWaitOn(addr *atomic.Uint32, val uint32) {
+	_, _, _ = syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(addr)), futexWait, uintptr(val), 0, 0, 0)
+}
+
+// futexWakeAll wakes every process or thread sleeping on addr.
+func futexWakeAll(addr *atomic.Uint32) {
+	_, _, _ = syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(addr)), futexWake, uintptr(^uint32(0)>>1), 0, 0, 0)
+}
+
+// runProcessProducer creates the shared memory, starts a consumer process that maps the same pages,
+// and produces into a ring buffer laid over it.
+// Use -shm=path to map a regular file (e.g. under /dev/shm) instead of a memfd.
+func runProcessProducer(shmPath string) {
+	var f *os.File
+	var err error
+	if shmPath == "" {
+		f, err = memfdCreate("shared_ipc")
+	} else {
+		f, err = os.OpenFile(shmPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+		defer os.Remove(shmPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Producer: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(int64(ringSize(SIZE))); err != nil { // a fresh memfd or file has size 0, mmap needs backing pages
+		fmt.Fprintf(os.Stderr, "Producer: truncate: %v\n", err)
+		os.Exit(1)
+	}
+	mem, err := mapShared(f, ringSize(SIZE))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Producer: %v\n", err)
+		os.Exit(1)
+	}
+	defer syscall.Munmap(mem)
+	r := newRing(mem, SIZE)
+
+	// the consumer is a separate process: it inherits the memfd as fd 3, or opens the same file
+	cmd := exec.Command(os.Args[0], "-role=consumer", "-shm="+shmPath, "process")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{f}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Producer: starting consumer: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Producer (pid %d): consumer started as pid %d\n", os.Getpid(), cmd.Process.Pid)
+
+	runProducer(fmt.Sprintf("Producer (pid %d)", os.Getpid()), r)
+
+	if err := cmd.Wait(); err != nil {
+		fmt.Fprintf(os.Stderr, "Producer: consumer error: %v\n", err)
+	}
+}
+
+// runProcessConsumer maps the producer's shared memory and pops from the ring buffer laid over it.
+func runProcessConsumer(shmPath string) {
+	var f *os.File
+	if shmPath == "" {
+		f = os.NewFile(3, "memfd:shared_ipc") // inherited through cmd.ExtraFiles
+	} else {
+		var err error
+		if f, err = os.OpenFile(shmPath, os.O_RDWR, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Consumer: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	defer f.Close()
+
+	mem, err := mapShared(f, ringSize(SIZE))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Consumer: %v\n", err)
+		os.Exit(1)
+	}
+	defer syscall.Munmap(mem)
+	runConsumer(fmt.Sprintf("Consumer (pid %d)", os.Getpid()), newRing(mem, SIZE))
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync/atomic"
+)
+
+// futexWaitOn has no portable equivalent, so waiting degrades to yielding and re-checking.
+func futexWaitOn(addr *atomic.Uint32, val uint32) {
+	if addr.Load() == val {
+		runtime.Gosched()
+	}
+}
+
+func futexWakeAll(addr *atomic.Uint32) {}
+
+func runProcessProducer(shmPath string) {
+	fmt.Fprintln(os.Stderr, "the cross-process shared memory example is only available on Linux")
+	os.Exit(1)
+}
+
+func runProcessConsumer(shmPath string) { runProcessProducer(shmPath) }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// datagramServer receives on a connectionless unixgram (SOCK_DGRAM) socket. There is nothing
+// to accept and no connection to wait for: every Read returns exactly one datagram,
+// one complete message from whichever socket sent it.
+type datagramServer struct {
+	conn   *net.UnixConn
+	path   string
+	handle func(msg []byte)
+	closed atomic.Bool
+	done   chan struct{} // closed when Serve returns
+}
+
+func listenDatagram(path string, handle func(msg []byte)) (*datagramServer, error) {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &datagramServer{conn: conn, path: path, handle: handle, done: make(chan struct{})}, nil
+}
+
+// Serve reads datagrams until Shutdown, then returns errServerClosed.
+func (s *datagramServer) Serve() error {
+	defer close(s.done)
+	// a datagram larger than the buffer is truncated, the rest of it is lost rather than read next
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			if s.closed.Load() {
+				return errServerClosed
+			}
+			return err
+		}
+		s.handle(buf[:n])
+	}
+}
+
+// Shutdown closes the socket, waits for Serve to return and removes the socket file,
+// which, unlike a listener's, is not removed on close.
+func (s *datagramServer) Shutdown(ctx context.Context) error {
+	s.closed.Store(true)
+	err := s.conn.Close()
+	os.Remove(s.path)
+	select {
+	case <-s.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeDatagram sends p as one datagram. The receiving socket queues only a few datagrams
+// (net.unix.max_dgram_qlen, 10 by default) and when that queue is full the send fails with EAGAIN
+// rather than blocking, so back off and retry until the receiver catches up or ctx ends.
+func writeDatagram(ctx context.Context, conn net.Conn, p []byte) error {
+	backoff := time.Millisecond
+	for {
+		_, err := conn.Write(p)
+		if !errors.Is(err, syscall.EAGAIN) {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w, receiver queue full: %w", ctx.Err(), err)
+		}
+		backoff = min(2*backoff, 100*time.Millisecond)
+	}
+}
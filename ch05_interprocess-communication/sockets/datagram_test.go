@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Every datagram arrives whole, and an empty one says goodbye, as runSender does.
+func TestDatagramRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.sock")
+	got := make(chan string, len(testMessages)+1)
+	srv, err := listenDatagram(path, func(msg []byte) { got <- string(msg) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve() }()
+
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	for _, msg := range append(testMessages, "") {
+		if err := writeDatagram(ctx, conn, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var msgs []string
+	for msg := range got {
+		if msg == "" {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+	if !slices.Equal(msgs, testMessages) {
+		t.Errorf("received %q, want %q", msgs, testMessages)
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if err := <-served; !errors.Is(err, errServerClosed) {
+		t.Errorf("Serve returned %v, want errServerClosed", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket file still there after Shutdown: %v", err)
+	}
+}
+
+// A slow receiver fills its small datagram queue; writeDatagram backs off instead of losing messages.
+func TestDatagramBackoffOnFullQueue(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("a full datagram queue fails the send with EAGAIN on Linux, other kernels report it differently")
+	}
+	path := filepath.Join(t.TempDir(), "s.sock")
+	const n = 50 // several times net.unix.max_dgram_qlen
+	got := make(chan string, n)
+	srv, err := listenDatagram(path, func(msg []byte) {
+		time.Sleep(time.Millisecond)
+		got <- string(msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := range n {
+		if err := writeDatagram(ctx, conn, fmt.Appendf(nil, "%d", i)); err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+	}
+	for i := range n {
+		if msg, want := <-got, fmt.Sprint(i); msg != want {
+			t.Fatalf("received %q, want %q", msg, want)
+		}
+	}
+}
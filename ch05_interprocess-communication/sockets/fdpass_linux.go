@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// A Unix socket can carry more than bytes. With SCM_RIGHTS ancillary data the sender hands over
+// open file descriptors: the kernel installs a duplicate in the receiving process, pointing at the
+// same open file (same offset, same access mode). The receiver needs no path and no permission
+// to open the file itself, which is why it should check who is on the other end: SO_PEERCRED
+// reports the pid, uid and gid of the peer process as they were when it connected.
+
+// peerCred returns the credentials of the process at the other end of conn.
+func peerCred(conn *net.UnixConn) (*syscall.Ucred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, credErr
+}
+
+// checkPeer accepts only peers running as our own user (or root).
+func checkPeer(conn *net.UnixConn) (*syscall.Ucred, error) {
+	cred, err := peerCred(conn)
+	if err != nil {
+		return nil, fmt.Errorf("SO_PEERCRED: %w", err)
+	}
+	if cred.Uid != uint32(os.Getuid()) && cred.Uid != 0 {
+		return cred, fmt.Errorf("peer pid %d runs as uid %d, not %d", cred.Pid, cred.Uid, os.Getuid())
+	}
+	return cred, nil
+}
+
+// sendFile passes f over conn as SCM_RIGHTS ancillary data, along with a short message.
+// At least one byte of normal data must go with it, the kernel does not send ancillary data alone.
+func sendFile(conn *net.UnixConn, f *os.File, msg string) error {
+	n, oobn, err := conn.WriteMsgUnix([]byte(msg), syscall.UnixRights(int(f.Fd())), nil)
+	if err == nil && (n != len(msg) || oobn == 0) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// receiveFile reads a message and the file descriptor passed along with it.
+func receiveFile(conn *net.UnixConn) (*os.File, string, error) {
+	buf := make([]byte, bufferSize)
+	oob := make([]byte, syscall.CmsgSpace(4)) // room for exactly one descriptor
+	n, oobn, flags, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, "", err
+	}
+	if flags&syscall.MSG_CTRUNC != 0 {
+		// descriptors that did not fit were closed by the kernel, they are gone
+		return nil, "", errors.New("ancillary data truncated")
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, "", err
+	}
+	if len(msgs) != 1 {
+		return nil, "", fmt.Errorf("expected one control message, got %d", len(msgs))
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return nil, "", err
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return nil, "", fmt.Errorf("expected one descriptor, got %d", len(fds))
+	}
+	return os.NewFile(uintptr(fds[0]), "passed"), string(buf[:n]), nil
+}
+
+// runFDPass is the receiving side: it starts a sender process and reads from the file it is handed.
+func runFDPass(ctx context.Context) error {
+	_ = os.Remove(sockFile)
+	ln, err := net.Listen("unix", sockFile)
+	if err != nil {
+		return fmt.Errorf("receiver: listen: %w", err)
+	}
+	defer os.Remove(sockFile)
+	fmt.Printf("Receiver (pid %d): Listening for a file descriptor...\n", os.Getpid())
+
+	handled := make(chan struct{})
+	done := sync.OnceFunc(func() { close(handled) })
+	srv := newServer(ln, func(id int, conn net.Conn) {
+		defer done()
+		receivePassedFile(conn.(*net.UnixConn))
+	})
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve() }()
+
+	// the sender is another process, started once the socket is listening
+	cmd := exec.Command(os.Args[0], "-role=sender", "fdpass")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return errors.Join(fmt.Errorf("receiver: starting sender: %w", err), stopReceiver(srv, served))
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	// a sender that fails before connecting must not leave us waiting for its file forever
+	select {
+	case <-handled:
+		err = <-exited
+	case err = <-exited:
+		if err == nil { // sent, but maybe not accepted yet: the file is waiting in the socket
+			select {
+			case <-handled:
+			case <-ctx.Done():
+			}
+		}
+	case <-ctx.Done():
+		fmt.Println("Receiver: interrupted")
+		err = <-exited // the signal reached the sender too
+	}
+	if err != nil {
+		err = fmt.Errorf("receiver: sender: %w", err)
+	}
+	return errors.Join(err, stopReceiver(srv, served))
+}
+
+func receivePassedFile(conn *net.UnixConn) {
+	cred, err := checkPeer(conn)
+	if err != nil {
+		fmt.Printf("Receiver: rejecting connection: %v\n", err)
+		return
+	}
+	fmt.Printf("Receiver: peer is pid %d, uid %d, gid %d\n", cred.Pid, cred.Uid, cred.Gid)
+
+	f, msg, err := receiveFile(conn)
+	if err != nil {
+		fmt.Printf("Receiver: receive error: %v\n", err)
+		return
+	}
+	defer f.Close()
+	fmt.Printf("Receiver: Received %q with fd %d\n", msg, f.Fd())
+
+	// the descriptor shares the sender's file offset, which it left at the start
+	data, err := io.ReadAll(f)
+	if err != nil {
+		fmt.Printf("Receiver: read error: %v\n", err)
+		return
+	}
+	fmt.Printf("Receiver: Read from the passed file: %q\n", data)
+}
+
+// runFDPassSender opens a file, removes its name, and hands the still-open descriptor to the receiver.
+func runFDPassSender(ctx context.Context) error {
+	name := fmt.Sprintf("Sender (pid %d)", os.Getpid())
+	f, err := os.CreateTemp("", "fdpass")
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer f.Close()
+	// with the name gone, the open descriptor is the only way left to reach the file
+	os.Remove(f.Name())
+	if _, err := f.WriteString("Hello from a file without a name"); err != nil {
+		return fmt.Errorf("%s: write: %w", name, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("%s: seek: %w", name, err)
+	}
+
+	c, err := dialRetry(ctx, "unix", sockFile, *dialTimeout)
+	if err != nil {
+		return fmt.Errorf("%s: dial: %w", name, err)
+	}
+	conn := c.(*net.UnixConn)
+	defer conn.Close()
+
+	// credentials work both ways: make sure the receiver is ours before handing over the file
+	if _, err := checkPeer(conn); err != nil {
+		return fmt.Errorf("%s: not sending to this receiver: %w", name, err)
+	}
+	fmt.Printf("%s: Send fd %d for %s\n", name, f.Fd(), f.Name())
+	if err := sendFile(conn, f, "unlinked temp file"); err != nil {
+		return fmt.Errorf("%s: send: %w", name, err)
+	}
+	return nil
+}
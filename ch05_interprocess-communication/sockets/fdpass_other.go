@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+var errFDPassUnsupported = errors.New("the file descriptor passing example is only available on Linux (it relies on SO_PEERCRED)")
+
+func runFDPass(ctx context.Context) error { return errFDPassUnsupported }
+
+func runFDPassSender(ctx context.Context) error { return errFDPassUnsupported }
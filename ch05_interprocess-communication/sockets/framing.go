@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// A stream socket, like a pipe, only moves bytes: one Write may arrive in several Reads
+// and several Writes may arrive in one. A length prefix puts the message boundaries back.
+
+// maxFrameSize bounds a single frame, so a corrupt length cannot make the reader allocate gigabytes.
+const maxFrameSize = 1 << 20
+
+var errFrameTooLarge = errors.New("frame too large")
+
+// writeFrame writes p as one frame: a 4-byte big-endian length, then the payload.
+func writeFrame(w io.Writer, p []byte) error {
+	if len(p) > maxFrameSize {
+		return errFrameTooLarge
+	}
+	// header and payload in one Write, so concurrent writers on one connection cannot interleave them
+	buf := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(p)), uint32(len(p)))
+	_, err := w.Write(append(buf, p...))
+	return err
+}
+
+// readFrame reads one frame written by writeFrame. It returns io.EOF at a clean frame boundary
+// and io.ErrUnexpectedEOF if the stream ends in the middle of a frame.
+func readFrame(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n > maxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(r, p); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net" // go exposes sockets via the net package
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// Remember: A socket file is not a socket. A socket is a kernel object.
+	// The file here is to facilitate the connection between threads,
+	// just like TCP port number is not the TCP connection itself.
+	sockFile = "./mailbox"
+	// Buffer size for receiving data from the socket connection.
+	bufferSize = 1024
+)
+
+// socketKind is one way of carrying the sender's messages, each with its own idea of a message boundary.
+type socketKind struct {
+	name    string
+	network string // the net package's name for the socket type
+	framed  bool   // length-prefix every message
+	about   string
+}
+
+var socketKinds = []socketKind{
+	{"stream", "unix", false, "SOCK_STREAM: a byte stream, writes may arrive merged or split"},
+	{"framed", "unix", true, "SOCK_STREAM with a length prefix per message: the receiver restores the boundaries"},
+	{"seqpacket", "unixpacket", false, "SOCK_SEQPACKET: connection-oriented, every write arrives as one record"},
+	{"datagram", "unixgram", false, "SOCK_DGRAM: connectionless, every write arrives as one datagram"},
+}
+
+func socketKindByName(name string) (socketKind, bool) {
+	for _, k := range socketKinds {
+		if k.name == name {
+			return k, true
+		}
+	}
+	return socketKind{}, false
+}
+
+var (
+	socket      = flag.String("socket", "stream", "socket type: stream, framed, seqpacket or datagram")
+	senders     = flag.Int("senders", 3, "demo mode: number of concurrent senders")
+	grace       = flag.Duration("grace", 5*time.Second, "how long shutdown waits for active connections")
+	name        = flag.String("name", "Sender", "send mode: name used in messages and output")
+	network     = flag.String("transport", "unix", "rpc mode: unix or tcp (loopback)")
+	codec       = flag.String("codec", "json", "rpc mode: payload encoding, json or gob")
+	dialTimeout = flag.Duration("dial-timeout", 5*time.Second, "how long a sender keeps retrying to connect")
+	role        = flag.String("role", "receiver", "fdpass mode: receiver or sender (the sender is started by the receiver)")
+)
+
+// dialRetry dials until the receiver answers, waiting longer after every failed attempt.
+// Before the receiver has called Listen the socket file does not exist (ENOENT), and a file
+// left behind by a dead receiver refuses connections (ECONNREFUSED); both may change any moment.
+// It gives up after timeout, or when ctx ends.
+func dialRetry(ctx context.Context, network, addr string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	backoff := 10 * time.Millisecond
+	for {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		if !errors.Is(err, syscall.ENOENT) && !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w, last attempt: %w", ctx.Err(), err)
+		}
+		backoff = min(2*backoff, 500*time.Millisecond)
+	}
+}
+
+// runSender sends the messages. reached is called once the receiver is bound to notice this sender:
+// on connecting, or for a datagram sender, once its goodbye has gone out.
+func runSender(ctx context.Context, kind socketKind, name string, reached func()) (err error) {
+	// Creates a client socket for this thread, and
+	// connects the socket to the "channel" (the mailbox file).
+	// A datagram socket is not really connected: Dial only fixes the destination of its writes.
+	conn, err := dialRetry(ctx, kind.network, sockFile, *dialTimeout)
+	if err != nil {
+		return fmt.Errorf("%s: dial: %w", name, err)
+	}
+	defer conn.Close()
+
+	datagram := kind.network == "unixgram"
+	if !datagram {
+		reached() // whatever happens next, the receiver sees this connection close
+	}
+
+	write := func(p []byte) error {
+		_, err := conn.Write(p)
+		return err
+	}
+	if kind.framed {
+		write = func(p []byte) error { return writeFrame(conn, p) }
+	}
+	if datagram {
+		write = func(p []byte) error { return writeDatagram(ctx, conn, p) }
+		// no connection, so closing tells the receiver nothing: say goodbye with an empty datagram,
+		// even when a send fails, so the receiver does not wait for us forever
+		defer func() {
+			if werr := write(nil); werr != nil {
+				err = errors.Join(err, fmt.Errorf("%s: write: %w", name, werr))
+				return
+			}
+			reached()
+		}()
+	}
+
+	// Sends a series of messages over the client socket
+	messages := []string{"Hello", " ", "world!"}
+	for _, msg := range messages {
+		fmt.Printf("%s: Send: %q\n", name, msg)
+		if err := write([]byte(msg)); err != nil {
+			return fmt.Errorf("%s: write: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// receive reads messages from one connection socket until the sender closes it.
+// On a stream socket every Read returns whatever bytes have arrived so far,
+// on a seqpacket socket exactly one record.
+func receive(id int, conn net.Conn) {
+	fmt.Printf("Receiver: connection %d accepted\n", id)
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			msg := string(buf[:n])
+			fmt.Printf("Receiver: connection %d: Received: %q\n", id, msg)
+		}
+		if err != nil {
+			break
+		}
+	}
+	fmt.Printf("Receiver: connection %d closed\n", id)
+}
+
+// receiveFramed reads length-prefixed messages from one stream connection until the sender closes it.
+func receiveFramed(id int, conn net.Conn) {
+	fmt.Printf("Receiver: connection %d accepted\n", id)
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Printf("Receiver: connection %d: read error: %v\n", id, err)
+			}
+			break
+		}
+		fmt.Printf("Receiver: connection %d: Received: %q\n", id, msg)
+	}
+	fmt.Printf("Receiver: connection %d closed\n", id)
+}
+
+// receiverServer is a running receiver, serving connections or datagrams.
+type receiverServer interface {
+	Serve() error
+	Shutdown(ctx context.Context) error
+}
+
+// startReceiver creates the listening socket and serves it in the background.
+// senderDone is called each time a sender has finished: its connection closed, or its goodbye datagram arrived.
+// The returned channel yields Serve's result once the server has stopped.
+func startReceiver(kind socketKind, senderDone func()) (receiverServer, <-chan error, error) {
+	_ = os.Remove(sockFile) // a previous run killed with SIGKILL leaves the file behind
+
+	var srv receiverServer
+	if kind.network == "unixgram" {
+		ds, err := listenDatagram(sockFile, func(msg []byte) {
+			if len(msg) == 0 {
+				senderDone()
+				return
+			}
+			fmt.Printf("Receiver: Received datagram: %q\n", msg)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		srv = ds
+	} else {
+		// Creates a listening socket for this thread,
+		// and binds it to a "channel" (the mailbox file),
+		// and starts listening for incoming connections.
+		ln, err := net.Listen(kind.network, sockFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		handle := receive
+		if kind.framed {
+			handle = receiveFramed
+		}
+		srv = newServer(ln, func(id int, conn net.Conn) {
+			defer senderDone()
+			handle(id, conn)
+		})
+	}
+	fmt.Printf("Receiver: Listening for incoming messages (%s)...\n", kind.about)
+
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve() }()
+	return srv, served, nil
+}
+
+// stopReceiver shuts srv down gracefully and reports how Serve ended.
+func stopReceiver(srv receiverServer, served <-chan error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *grace)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		err = fmt.Errorf("receiver: shutdown: %w", err)
+	}
+	if serr := <-served; !errors.Is(serr, errServerClosed) {
+		err = errors.Join(err, fmt.Errorf("receiver: %w", serr))
+	}
+	fmt.Println("Receiver: Shut down.")
+	return err
+}
+
+// runReceiver listens, closes ready as soon as senders can connect, and serves until ctx ends.
+// Then it shuts down gracefully. An accept error stops it early.
+func runReceiver(ctx context.Context, kind socketKind, senderDone func(), ready chan<- struct{}) error {
+	srv, served, err := startReceiver(kind, senderDone)
+	if err != nil {
+		return fmt.Errorf("receiver: listen: %w", err)
+	}
+	defer os.Remove(sockFile) // Shutdown removes it too, this also covers a panic before that
+	close(ready)
+
+	select {
+	case <-ctx.Done():
+		return stopReceiver(srv, served)
+	case err := <-served:
+		srv.Shutdown(context.Background()) // closes the listener and removes the socket file
+		return fmt.Errorf("receiver: %w", err)
+	}
+}
+
+// runDemo serves several senders at once, then shuts the receiver down once they are done.
+func runDemo(ctx context.Context, kind socketKind) error {
+	// A sender is done as soon as its bytes are in the kernel, possibly before the receiver has even
+	// accepted the connection, and closing the listener drops connections not accepted yet.
+	// So the demo waits for the receiver to have handled every sender that got through,
+	// not just for the senders.
+	handled := make(chan struct{}, *senders)
+	var reached atomic.Int32
+
+	// not ctx: on a signal the receiver still shuts down gracefully, after the senders
+	recvCtx, stopReceiving := context.WithCancel(context.Background())
+	defer stopReceiving()
+	ready := make(chan struct{})
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- runReceiver(recvCtx, kind, func() { handled <- struct{}{} }, ready)
+	}()
+
+	select {
+	case <-ready: // listening, senders can connect now
+	case err := <-recvErr:
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, *senders)
+	for i := range *senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = runSender(ctx, kind, fmt.Sprintf("Sender %d", i+1), func() { reached.Add(1) })
+		}()
+	}
+	wg.Wait() // block until every sender completes
+
+	for range reached.Load() {
+		select {
+		case <-handled:
+		case <-ctx.Done():
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Println("Receiver: interrupted")
+	}
+	stopReceiving()
+	return errors.Join(append(errs, <-recvErr)...)
+}
+
+// runServe keeps the receiver running until SIGINT or SIGTERM; senders connect with `go run . send`.
+func runServe(ctx context.Context, kind socketKind) error {
+	recvCtx, stopReceiving := context.WithCancel(context.Background())
+	defer stopReceiving()
+	ready := make(chan struct{})
+	recvErr := make(chan error, 1)
+	go func() { recvErr <- runReceiver(recvCtx, kind, func() {}, ready) }()
+
+	select {
+	case <-ready:
+	case err := <-recvErr:
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		fmt.Println("Receiver: signal received, shutting down")
+		stopReceiving()
+		return <-recvErr
+	case err := <-recvErr:
+		return err
+	}
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . [-socket=type] [-senders=n] [-grace=d] [-transport=t] [-codec=c] [demo|compare|serve|send|rpc|fdpass]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// on Ctrl-C or kill, shut down gracefully instead of dying with the socket file left behind
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	kind, ok := socketKindByName(*socket)
+	if !ok {
+		fmt.Printf("Unknown socket type %q. Use 'stream', 'framed', 'seqpacket' or 'datagram'.\n", *socket)
+		os.Exit(2)
+	}
+
+	var err error
+	switch mode := flag.Arg(0); mode {
+	case "", "demo":
+		err = runDemo(ctx, kind)
+	case "compare":
+		// the same senders over every socket type, to see where the message boundaries survive
+		for _, kind := range socketKinds {
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Printf("\n=== %s ===\n", kind.name)
+			err = errors.Join(err, runDemo(ctx, kind))
+		}
+	case "serve":
+		err = runServe(ctx, kind)
+	case "send":
+		err = runSender(ctx, kind, *name, func() {})
+	case "rpc":
+		var t transport
+		var c payloadCodec
+		if t, err = transportByName(*network); err != nil {
+			break
+		}
+		if c, err = payloadCodecByName(*codec); err != nil {
+			break
+		}
+		err = runRPC(ctx, t, c)
+	case "fdpass":
+		if *role == "sender" {
+			err = runFDPassSender(ctx)
+		} else {
+			err = runFDPass(ctx)
+		}
+	default:
+		fmt.Printf("Unknown mode %q. Use 'demo', 'compare', 'serve', 'send', 'rpc' or 'fdpass'.\n", mode)
+		os.Exit(1)
+	}
+	if err != nil {
+		stop()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
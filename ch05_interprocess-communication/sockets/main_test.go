@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var testMessages = []string{"Hello", " ", "world!"}
+
+// messageReader returns the next message from a connection, one call per message.
+type messageReader func() ([]byte, error)
+
+// roundTrip serves one connection on network at a temporary path, sends testMessages with write,
+// and returns the messages the server read from it with the reader made by newReader.
+func roundTrip(t *testing.T, network string, write func(net.Conn, []byte) error, newReader func(net.Conn) messageReader) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "s.sock")
+	ln, err := net.Listen(network, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan []string, 1)
+	srv := newServer(ln, func(_ int, conn net.Conn) {
+		var msgs []string
+		read := newReader(conn)
+		for {
+			msg, err := read()
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, string(msg))
+		}
+		got <- msgs
+	})
+	go srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	conn, err := net.Dial(network, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range testMessages {
+		if err := write(conn, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+	return <-got
+}
+
+// Every write on a seqpacket socket arrives as one record, although each read has room for all of them.
+func TestSeqpacketRoundTrip(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SOCK_SEQPACKET on Unix sockets is Linux only")
+	}
+	write := func(conn net.Conn, p []byte) error {
+		_, err := conn.Write(p)
+		return err
+	}
+	records := func(conn net.Conn) messageReader {
+		buf := make([]byte, bufferSize)
+		return func() ([]byte, error) {
+			n, err := conn.Read(buf)
+			return bytes.Clone(buf[:n]), err
+		}
+	}
+	if got := roundTrip(t, "unixpacket", write, records); !slices.Equal(got, testMessages) {
+		t.Errorf("received %q, want %q", got, testMessages)
+	}
+}
+
+// On a stream socket the length prefix restores the boundaries the socket does not keep.
+func TestFramedStreamRoundTrip(t *testing.T) {
+	write := func(conn net.Conn, p []byte) error { return writeFrame(conn, p) }
+	frames := func(conn net.Conn) messageReader {
+		r := bufio.NewReader(conn)
+		return func() ([]byte, error) { return readFrame(r) }
+	}
+	if got := roundTrip(t, "unix", write, frames); !slices.Equal(got, testMessages) {
+		t.Errorf("received %q, want %q", got, testMessages)
+	}
+}
+
+// listenLater starts listening on path after delay and accepts one connection.
+func listenLater(t *testing.T, path string, delay time.Duration) {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() {
+		time.Sleep(delay)
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer ln.Close()
+		conn, err := ln.Accept() // the dial that got through waits in the backlog until then
+		if err == nil {
+			conn.Close()
+		}
+		errc <- err
+	}()
+	t.Cleanup(func() {
+		if err := <-errc; err != nil {
+			t.Errorf("listener: %v", err)
+		}
+	})
+}
+
+// The sender may start first: until the socket file exists every dial fails with ENOENT.
+func TestDialRetryWaitsForSocketFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.sock")
+	listenLater(t, path, 50*time.Millisecond)
+
+	conn, err := dialRetry(context.Background(), "unix", path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dialRetry: %v", err)
+	}
+	conn.Close()
+}
+
+// A socket file left behind by a dead receiver refuses connections until a new receiver replaces it.
+func TestDialRetryWaitsForStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := net.Dial("unix", path); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("dial of a stale socket returned %v, want ECONNREFUSED", err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		errc <- os.Remove(path) // as the receiver does before it listens
+	}()
+	listenLater(t, path, 100*time.Millisecond)
+
+	conn, err := dialRetry(context.Background(), "unix", path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dialRetry: %v", err)
+	}
+	conn.Close()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialRetryTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.sock")
+	start := time.Now()
+	_, err := dialRetry(context.Background(), "unix", path, 100*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("dialRetry returned %v, want the deadline and the last ENOENT", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("dialRetry gave up after %s with a 100ms timeout", d)
+	}
+}
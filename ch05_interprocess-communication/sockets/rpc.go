@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A small request/response RPC layer over any stream transport. Every message is one frame
+// (see framing.go) made of a fixed header, the method name and the encoded payload:
+//
+//	kind     1 byte   request, response or error
+//	id       8 bytes  chosen by the client, copied into the response
+//	deadline 8 bytes  requests only: UnixNano after which the caller gives up, 0 for none
+//	method   2-byte length, then the name
+//	payload  the rest, encoded by the payload codec (an error message for errors)
+//
+// The id is what allows many calls to be in flight on one connection at once: the server answers
+// each request as soon as it is done, in any order, and the client routes every response to
+// the call waiting for that id.
+
+const (
+	rpcRequest byte = iota
+	rpcResponse
+	rpcError
+)
+
+const rpcHeaderSize = 1 + 8 + 8 + 2
+
+type rpcMessage struct {
+	kind     byte
+	id       uint64
+	deadline time.Time
+	method   string
+	payload  []byte
+}
+
+// marshal encodes m; it fails if the method name does not fit its 2-byte length.
+func (m *rpcMessage) marshal() ([]byte, error) {
+	if len(m.method) > math.MaxUint16 {
+		return nil, fmt.Errorf("rpc: method name of %d bytes, at most %d fit", len(m.method), math.MaxUint16)
+	}
+	b := make([]byte, 0, rpcHeaderSize+len(m.method)+len(m.payload))
+	b = append(b, m.kind)
+	b = binary.BigEndian.AppendUint64(b, m.id)
+	var deadline int64
+	if !m.deadline.IsZero() {
+		deadline = m.deadline.UnixNano()
+	}
+	b = binary.BigEndian.AppendUint64(b, uint64(deadline))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(m.method)))
+	b = append(b, m.method...)
+	return append(b, m.payload...), nil
+}
+
+func unmarshalRPC(b []byte) (rpcMessage, error) {
+	if len(b) < rpcHeaderSize {
+		return rpcMessage{}, errors.New("rpc: short message")
+	}
+	m := rpcMessage{kind: b[0], id: binary.BigEndian.Uint64(b[1:])}
+	if d := int64(binary.BigEndian.Uint64(b[9:])); d != 0 {
+		m.deadline = time.Unix(0, d)
+	}
+	n := int(binary.BigEndian.Uint16(b[17:]))
+	b = b[rpcHeaderSize:]
+	if len(b) < n {
+		return rpcMessage{}, errors.New("rpc: short method name")
+	}
+	m.method, m.payload = string(b[:n]), b[n:]
+	return m, nil
+}
+
+// --------------------
+// Payload codecs
+// --------------------
+
+// payloadCodec encodes request and response values; both sides must use the same one.
+type payloadCodec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// gobCodec encodes every payload as a self-contained gob stream, type description included.
+// That costs a few dozen bytes per message, but no state is shared between messages,
+// so responses may go out in any order.
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func payloadCodecByName(name string) (payloadCodec, error) {
+	for _, c := range []payloadCodec{jsonCodec{}, gobCodec{}} {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+// --------------------
+// Server
+// --------------------
+
+// rpcHandler serves one request. ctx ends at the caller's deadline, or when the client goes away.
+type rpcHandler func(ctx context.Context, payload []byte) ([]byte, error)
+
+type rpcServer struct {
+	codec   payloadCodec
+	methods map[string]rpcHandler
+}
+
+func newRPCServer(codec payloadCodec) *rpcServer {
+	return &rpcServer{codec: codec, methods: make(map[string]rpcHandler)}
+}
+
+// handle registers fn as method, decoding its request and encoding its response with the server's codec.
+func handle[Req, Resp any](s *rpcServer, method string, fn func(context.Context, Req) (Resp, error)) {
+	s.methods[method] = func(ctx context.Context, payload []byte) ([]byte, error) {
+		var req Req
+		if err := s.codec.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("decoding request: %w", err)
+		}
+		resp, err := fn(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return s.codec.Marshal(resp)
+	}
+}
+
+// serveConn is a connHandler: it reads requests and runs each in its own goroutine,
+// so a slow call does not hold up the ones behind it. It returns once the client has closed
+// the connection and every call in flight has answered.
+func (s *rpcServer) serveConn(id int, conn net.Conn) {
+	var inFlight sync.WaitGroup
+	defer inFlight.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // runs before the Wait: once the client is gone, calls in flight are abandoned
+
+	var writeMu sync.Mutex // one response frame at a time
+	reply := func(m rpcMessage) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		b, err := m.marshal() // cannot fail: the method name came in a request
+		if err == nil {
+			err = writeFrame(conn, b)
+		}
+		if err != nil {
+			cancel() // the client cannot hear us any more
+		}
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		b, err := readFrame(r)
+		if err != nil {
+			return // EOF when the client closes, or the connection broke
+		}
+		req, err := unmarshalRPC(b)
+		if err != nil || req.kind != rpcRequest {
+			return // not speaking our protocol
+		}
+		inFlight.Add(1)
+		go func() {
+			defer inFlight.Done()
+			resp := rpcMessage{kind: rpcResponse, id: req.id, method: req.method}
+			payload, err := s.call(ctx, req)
+			if err != nil {
+				resp.kind, payload = rpcError, []byte(err.Error())
+			}
+			resp.payload = payload
+			reply(resp)
+		}()
+	}
+}
+
+func (s *rpcServer) call(ctx context.Context, req rpcMessage) ([]byte, error) {
+	fn, ok := s.methods[req.method]
+	if !ok {
+		return nil, fmt.Errorf("unknown method %q", req.method)
+	}
+	if !req.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, req.deadline)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err // expired on the way here, the caller has given up already
+	}
+	return fn(ctx, req.payload)
+}
+
+// --------------------
+// Client
+// --------------------
+
+// rpcRemoteError is an error returned by the method on the server.
+type rpcRemoteError struct {
+	method, msg string
+}
+
+func (e *rpcRemoteError) Error() string { return e.method + ": " + e.msg }
+
+// rpcClient multiplexes concurrent calls over one connection.
+type rpcClient struct {
+	conn  net.Conn
+	codec payloadCodec
+
+	writeMu sync.Mutex // one request frame at a time
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan rpcMessage // calls waiting for their response
+	err     error                      // why the connection is no longer usable
+}
+
+func dialRPC(ctx context.Context, t transport, codec payloadCodec) (*rpcClient, error) {
+	conn, err := t.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newRPCClient(conn, codec), nil
+}
+
+// newRPCClient makes calls over an established connection, which it owns from now on.
+func newRPCClient(conn net.Conn, codec payloadCodec) *rpcClient {
+	c := &rpcClient{conn: conn, codec: codec, pending: make(map[uint64]chan rpcMessage)}
+	go c.readLoop()
+	return c
+}
+
+// readLoop hands every response to the call waiting for its id.
+// When the connection fails, every waiting call fails with it.
+func (c *rpcClient) readLoop() {
+	r := bufio.NewReader(c.conn)
+	var err error
+	for {
+		var b []byte
+		if b, err = readFrame(r); err != nil {
+			break
+		}
+		var m rpcMessage
+		if m, err = unmarshalRPC(b); err != nil {
+			break
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[m.id]
+		delete(c.pending, m.id)
+		c.mu.Unlock()
+		if ok { // no one waits for a call that gave up at its deadline
+			ch <- m
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = fmt.Errorf("rpc: connection lost: %w", err)
+	}
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// Call invokes method with req and decodes the result into resp.
+// The deadline of ctx travels with the request, so the server stops working on it in time as well.
+func (c *rpcClient) Call(ctx context.Context, method string, req, resp any) error {
+	payload, err := c.codec.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("%s: encoding request: %w", method, err)
+	}
+
+	ch := make(chan rpcMessage, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	forget := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+
+	deadline, _ := ctx.Deadline()
+	msg := rpcMessage{kind: rpcRequest, id: id, deadline: deadline, method: method, payload: payload}
+	b, err := msg.marshal()
+	if err != nil {
+		forget()
+		return err
+	}
+	c.writeMu.Lock()
+	c.conn.SetWriteDeadline(deadline) // zero means none
+	err = writeFrame(c.conn, b)
+	c.writeMu.Unlock()
+	if errors.Is(err, errFrameTooLarge) { // refused before writing anything, the stream is intact
+		forget()
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if err != nil {
+		// a write cut short, e.g. by the deadline, may leave part of a frame on the stream,
+		// and the server would read the next request from the middle of it: the connection is done for
+		forget()
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = fmt.Errorf("rpc: connection broken: %w", err)
+		}
+		c.mu.Unlock()
+		c.conn.Close() // readLoop fails the other waiting calls
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	select {
+	case m, ok := <-ch:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.err
+		}
+		if m.kind == rpcError {
+			return &rpcRemoteError{method: method, msg: string(m.payload)}
+		}
+		return c.codec.Unmarshal(m.payload, resp)
+	case <-ctx.Done():
+		forget() // a late response is dropped by readLoop
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// Close closes the connection; calls still waiting fail.
+func (c *rpcClient) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = errors.New("rpc: client closed")
+	}
+	c.mu.Unlock()
+	return c.conn.Close()
+}
+
+// --------------------
+// Demo
+// --------------------
+
+type addArgs struct {
+	A, B int
+}
+
+// runRPC serves a few methods over the chosen transport and calls them concurrently over one connection.
+func runRPC(ctx context.Context, t transport, codec payloadCodec) (err error) {
+	rs := newRPCServer(codec)
+	handle(rs, "upper", func(_ context.Context, s string) (string, error) { return strings.ToUpper(s), nil })
+	handle(rs, "add", func(_ context.Context, a addArgs) (int, error) { return a.A + a.B, nil })
+	handle(rs, "sleep", func(ctx context.Context, d time.Duration) (string, error) {
+		select {
+		case <-time.After(d):
+			return "slept " + d.String(), nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+
+	ln, err := t.Listen()
+	if err != nil {
+		return fmt.Errorf("server: listen: %w", err)
+	}
+	if ut, ok := t.(*unixTransport); ok {
+		defer os.Remove(ut.path) // Shutdown removes it too, this also covers a panic before that
+	}
+	srv := newServer(ln, rs.serveConn)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve() }()
+	defer func() { err = errors.Join(err, stopReceiver(srv, served)) }()
+	fmt.Printf("Server: serving RPC over %s, payloads encoded as %s\n", t.Name(), codec.Name())
+
+	client, err := dialRPC(ctx, t, codec)
+	if err != nil {
+		return fmt.Errorf("client: dial: %w", err)
+	}
+	defer client.Close()
+
+	var upper string
+	if err := client.Call(ctx, "upper", "rubber duck", &upper); err != nil {
+		fmt.Printf("Client: upper: %v\n", err)
+	} else {
+		fmt.Printf("Client: upper(%q) = %q\n", "rubber duck", upper)
+	}
+	var sum int
+	if err := client.Call(ctx, "add", addArgs{A: 2, B: 3}, &sum); err != nil {
+		fmt.Printf("Client: add: %v\n", err)
+	} else {
+		fmt.Printf("Client: add(2, 3) = %d\n", sum)
+	}
+	var none string
+	if err := client.Call(ctx, "divide", addArgs{A: 1}, &none); err != nil {
+		fmt.Printf("Client: %v\n", err)
+	}
+
+	// all in flight on the same connection at once: they finish in order of duration, not of sending
+	fmt.Println("Client: calling sleep 300ms, 100ms, 200ms and a 1s one with a 250ms deadline, concurrently")
+	var wg sync.WaitGroup
+	for _, d := range []time.Duration{300 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, time.Second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			timeout := 500 * time.Millisecond
+			if d == time.Second {
+				timeout = 250 * time.Millisecond
+			}
+			callCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			var res string
+			if err := client.Call(callCtx, "sleep", d, &res); err != nil {
+				fmt.Printf("Client: sleep(%s): %v\n", d, err)
+				return
+			}
+			fmt.Printf("Client: sleep(%s) = %q\n", d, res)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
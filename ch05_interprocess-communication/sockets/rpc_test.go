@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startRPC serves the test methods on a temporary Unix socket and returns a client connected to it.
+// abandoned receives the ctx error of every "wait" call once its caller has given up.
+func startRPC(t *testing.T, codec payloadCodec) (client *rpcClient, abandoned <-chan error) {
+	t.Helper()
+	rs := newRPCServer(codec)
+	handle(rs, "sleep", func(ctx context.Context, d time.Duration) (time.Duration, error) {
+		select {
+		case <-time.After(d):
+			return d, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+	waits := make(chan error, 10)
+	handle(rs, "wait", func(ctx context.Context, _ int) (int, error) {
+		<-ctx.Done()
+		waits <- ctx.Err()
+		return 0, ctx.Err()
+	})
+	handle(rs, "add", func(_ context.Context, a addArgs) (int, error) { return a.A + a.B, nil })
+
+	tr := &unixTransport{path: filepath.Join(t.TempDir(), "rpc.sock")}
+	ln, err := tr.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(ln, rs.serveConn)
+	go srv.Serve()
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	client, err = dialRPC(context.Background(), tr, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() }) // before Shutdown, which waits for the connection to end
+	return client, waits
+}
+
+// Calls sent together on one connection are answered as each finishes,
+// and every response reaches the call with its id.
+func TestRPCConcurrentCallsOutOfOrder(t *testing.T) {
+	for _, codec := range []payloadCodec{jsonCodec{}, gobCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			client, _ := startRPC(t, codec)
+			durations := []time.Duration{90 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond}
+			finished := make(chan time.Duration, len(durations))
+			var wg sync.WaitGroup
+			for _, d := range durations {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var got time.Duration
+					if err := client.Call(context.Background(), "sleep", d, &got); err != nil {
+						t.Errorf("sleep(%s): %v", d, err)
+						return
+					}
+					if got != d {
+						t.Errorf("sleep(%s) returned the response to sleep(%s)", d, got)
+					}
+					finished <- got
+				}()
+			}
+			wg.Wait()
+			close(finished)
+
+			var order []time.Duration
+			for d := range finished {
+				order = append(order, d)
+			}
+			if len(order) == 3 && (order[0] != durations[1] || order[1] != durations[2] || order[2] != durations[0]) {
+				t.Errorf("finished in order %v, want the shortest first", order)
+			}
+		})
+	}
+}
+
+// A call that runs out of time fails at its deadline, the server abandons it,
+// and the connection stays usable: the late response is dropped.
+func TestRPCDeadline(t *testing.T) {
+	client, abandoned := startRPC(t, jsonCodec{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var res int
+	err := client.Call(ctx, "wait", 0, &res)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Call returned %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Call returned after %s with a 50ms deadline", d)
+	}
+	select {
+	case err := <-abandoned:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("server side ctx ended with %v, want the caller's deadline", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("the server kept working on a call past its deadline")
+	}
+
+	var sum int
+	if err := client.Call(context.Background(), "add", addArgs{A: 2, B: 3}, &sum); err != nil || sum != 5 {
+		t.Errorf("add after a timed out call = %d, %v; want 5", sum, err)
+	}
+}
+
+func TestRPCRemoteError(t *testing.T) {
+	client, _ := startRPC(t, jsonCodec{})
+	var res int
+	err := client.Call(context.Background(), "divide", addArgs{A: 1}, &res)
+	var remote *rpcRemoteError
+	if !errors.As(err, &remote) || !strings.Contains(remote.msg, "unknown method") {
+		t.Errorf("Call of an unknown method returned %v, want an rpcRemoteError", err)
+	}
+}
+
+func TestRPCMethodNameTooLong(t *testing.T) {
+	client, _ := startRPC(t, jsonCodec{})
+	var res int
+	if err := client.Call(context.Background(), strings.Repeat("m", 1<<16), 0, &res); err == nil {
+		t.Fatal("a method name longer than its 2-byte length was accepted")
+	}
+	// refused before anything was written, so the connection is still in sync
+	if err := client.Call(context.Background(), "add", addArgs{A: 1, B: 1}, &res); err != nil || res != 2 {
+		t.Errorf("add after the refused call = %d, %v; want 2", res, err)
+	}
+}
+
+// pipeClient returns a client on one end of an in-memory connection and a reader for the requests
+// arriving on the other end, which serves nothing unless the test does.
+func pipeClient(t *testing.T) (*rpcClient, net.Conn, func() rpcMessage) {
+	t.Helper()
+	clientEnd, serverEnd := net.Pipe()
+	client := newRPCClient(clientEnd, jsonCodec{})
+	t.Cleanup(func() {
+		client.Close()
+		serverEnd.Close()
+	})
+	r := bufio.NewReader(serverEnd)
+	next := func() rpcMessage {
+		b, err := readFrame(r)
+		if err != nil {
+			t.Fatalf("server: %v", err)
+		}
+		m, err := unmarshalRPC(b)
+		if err != nil {
+			t.Fatalf("server: %v", err)
+		}
+		return m
+	}
+	return client, serverEnd, next
+}
+
+// A write cut short by its deadline may leave half a frame on the stream: the client must give up
+// on the connection, failing the calls waiting on it and every call after.
+func TestRPCBrokenWriteFailsPendingCalls(t *testing.T) {
+	client, _, next := pipeClient(t)
+
+	pending := make(chan error, 1)
+	go func() {
+		var res int
+		pending <- client.Call(context.Background(), "add", addArgs{A: 1, B: 2}, &res)
+	}()
+	next() // the server reads the first request, then stops reading
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var res int
+	if err := client.Call(ctx, "add", addArgs{A: 3, B: 4}, &res); err == nil {
+		t.Fatal("a call whose write timed out succeeded")
+	}
+
+	select {
+	case err := <-pending:
+		if err == nil || !strings.Contains(err.Error(), "connection") {
+			t.Errorf("pending call returned %v, want the broken connection", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the pending call still waits on a broken connection")
+	}
+	if err := client.Call(context.Background(), "add", addArgs{}, &res); err == nil {
+		t.Error("a call on a broken client succeeded")
+	}
+}
+
+// When the server goes away, every waiting call fails instead of waiting forever.
+func TestRPCConnectionClosedFailsPendingCalls(t *testing.T) {
+	client, serverEnd, next := pipeClient(t)
+
+	const calls = 3
+	pending := make(chan error, calls)
+	for range calls {
+		go func() {
+			var res int
+			pending <- client.Call(context.Background(), "add", addArgs{}, &res)
+		}()
+	}
+	for range calls {
+		next()
+	}
+	serverEnd.Close()
+
+	for range calls {
+		select {
+		case err := <-pending:
+			if err == nil || !strings.Contains(err.Error(), "connection lost") {
+				t.Errorf("pending call returned %v, want connection lost", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("a pending call still waits on a closed connection")
+		}
+	}
+}
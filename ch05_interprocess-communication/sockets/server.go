@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime/debug"
+	"sync"
+)
+
+// errServerClosed is returned by Serve once Shutdown has been called.
+var errServerClosed = errors.New("server closed")
+
+// connHandler serves one accepted connection. id numbers the connections in accept order.
+type connHandler func(id int, conn net.Conn)
+
+// server accepts connections on a listening socket and serves each client in its own goroutine,
+// so one slow client no longer holds up the others.
+type server struct {
+	ln     net.Listener
+	handle connHandler
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{} // active connections, force-closed if Shutdown runs out of time
+	nextID   int
+	shutdown bool
+
+	active sync.WaitGroup // one per connection goroutine
+}
+
+func newServer(ln net.Listener, handle connHandler) *server {
+	return &server{ln: ln, handle: handle, conns: make(map[net.Conn]struct{})}
+}
+
+// Serve accepts connections until the listener is closed.
+// After Shutdown it returns errServerClosed, otherwise the accept error.
+func (s *server) Serve() error {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if s.closing() {
+				return errServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.shutdown { // accepted just before the listener closed
+			s.mu.Unlock()
+			conn.Close()
+			return errServerClosed
+		}
+		s.nextID++
+		id := s.nextID
+		s.conns[conn] = struct{}{}
+		s.active.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(id, conn)
+	}
+}
+
+func (s *server) serveConn(id int, conn net.Conn) {
+	defer s.active.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	// a panicking handler would otherwise take the whole server down, socket file and all
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "Receiver: connection %d panicked: %v\n%s", id, r, debug.Stack())
+		}
+	}()
+	s.handle(id, conn)
+}
+
+func (s *server) closing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+// Shutdown stops accepting new connections, then waits for the active ones to finish.
+// If ctx ends first, the remaining connections are closed and ctx's error is returned.
+// Closing a Unix listener also removes its socket file.
+func (s *server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown = true
+	s.mu.Unlock()
+	err := s.ln.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close() // unblocks the handler's Read
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
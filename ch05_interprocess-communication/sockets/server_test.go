@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startTestServer serves a Unix socket in a temporary directory with handle.
+func startTestServer(t *testing.T, handle connHandler) (srv *server, path string, served <-chan error) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "s.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv = newServer(ln, handle)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve() }()
+	return srv, path, errc
+}
+
+// readAll is a handler that reports that it started, reads until the client closes,
+// then reports that it returned.
+func readAll(started, returned chan<- int) connHandler {
+	return func(id int, conn net.Conn) {
+		started <- id
+		io.Copy(io.Discard, conn)
+		returned <- id
+	}
+}
+
+func TestShutdownDrainsActiveConnections(t *testing.T) {
+	started, returned := make(chan int, 1), make(chan int, 1)
+	srv, path, served := startTestServer(t, readAll(started, returned))
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	<-started // accepted: a connection still in the listen backlog is dropped by Shutdown
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shut := make(chan error, 1)
+	go func() { shut <- srv.Shutdown(ctx) }()
+
+	select {
+	case err := <-shut:
+		t.Fatalf("Shutdown returned %v while a connection was still active", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket file still there once Shutdown closed the listener: %v", err)
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		t.Error("a new connection got through during Shutdown")
+	}
+
+	conn.Close() // the client is done: the handler returns and Shutdown with it
+	if err := <-shut; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if id := <-returned; id != 1 {
+		t.Errorf("handler of connection %d returned, want 1", id)
+	}
+	if err := <-served; !errors.Is(err, errServerClosed) {
+		t.Errorf("Serve returned %v, want errServerClosed", err)
+	}
+}
+
+func TestShutdownGraceTimeout(t *testing.T) {
+	started, returned := make(chan int, 1), make(chan int, 1)
+	srv, path, served := startTestServer(t, readAll(started, returned))
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() // never closed before Shutdown: a client that overstays the grace period
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown returned %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Shutdown took %s with a 50ms grace period", d)
+	}
+	select {
+	case <-returned: // its connection was closed under it
+	default:
+		t.Error("Shutdown returned before the handler did")
+	}
+	if err := <-served; !errors.Is(err, errServerClosed) {
+		t.Errorf("Serve returned %v, want errServerClosed", err)
+	}
+}
+
+func TestServerRecoversHandlerPanic(t *testing.T) {
+	calls := make(chan int, 2)
+	srv, path, _ := startTestServer(t, func(id int, conn net.Conn) {
+		calls <- id
+		if id == 1 {
+			panic("handler bug")
+		}
+	})
+	for range 2 {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, conn) // the server closes the connection when the handler ends
+		conn.Close()
+	}
+	if a, b := <-calls, <-calls; a != 1 || b != 2 {
+		t.Errorf("handled connections %d and %d, want 1 and 2", a, b)
+	}
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
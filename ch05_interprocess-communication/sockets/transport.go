@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+)
+
+// transport is where a server listens and how a client reaches it.
+// Everything above it works on plain net.Conn streams, so the RPC layer runs unchanged
+// over a Unix socket or over TCP on the loopback interface.
+type transport interface {
+	Name() string
+	Listen() (net.Listener, error)
+	Dial(ctx context.Context) (net.Conn, error)
+}
+
+// unixTransport is a stream socket bound to a file path, reachable from this machine only.
+type unixTransport struct {
+	path string
+}
+
+func (t *unixTransport) Name() string { return "unix " + t.path }
+
+func (t *unixTransport) Listen() (net.Listener, error) {
+	_ = os.Remove(t.path) // a previous run killed with SIGKILL leaves the file behind
+	return net.Listen("unix", t.path)
+}
+
+func (t *unixTransport) Dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", t.path)
+}
+
+// tcpTransport is a TCP socket on the loopback interface. With port 0 the kernel picks a free port,
+// which Listen records so that Dial connects to it.
+type tcpTransport struct {
+	addr string
+}
+
+func (t *tcpTransport) Name() string { return "tcp " + t.addr }
+
+func (t *tcpTransport) Listen() (net.Listener, error) {
+	ln, err := net.Listen("tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+	t.addr = ln.Addr().String()
+	return ln, nil
+}
+
+func (t *tcpTransport) Dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", t.addr)
+}
+
+func transportByName(name string) (transport, error) {
+	switch name {
+	case "unix":
+		return &unixTransport{path: sockFile}, nil
+	case "tcp":
+		return &tcpTransport{addr: "127.0.0.1:0"}, nil
+	}
+	return nil, fmt.Errorf("unknown transport %q", name)
+}
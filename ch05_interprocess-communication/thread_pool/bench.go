@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// benchSink keeps the compiler from optimizing the busy loop away
+var benchSink atomic.Uint64
+
+// spin burns a little CPU, standing in for the work done at one node of a recursive computation
+func spin(iterations int) {
+	x := uint64(iterations)
+	for i := range iterations {
+		x = x*6364136223846793005 + uint64(i)
+	}
+	benchSink.Add(x)
+}
+
+// treeNodes is the number of nodes in a full binary tree of the given depth
+func treeNodes(depth int) int { return 1<<(depth+1) - 1 }
+
+// treeOnChannelPool walks a binary tree where every node is a task that submits its two children.
+// All workers share one channel, so every spawn and every pickup goes through the same queue.
+func treeOnChannelPool(pool *threadPool, depth, work int, visited *atomic.Int64) task {
+	return func(string) {
+		spin(work)
+		visited.Add(1)
+		if depth > 0 {
+			pool.submit(treeOnChannelPool(pool, depth-1, work, visited))
+			pool.submit(treeOnChannelPool(pool, depth-1, work, visited))
+		}
+	}
+}
+
+// treeOnStealPool is the same walk, but children land on the spawning worker's own deque.
+func treeOnStealPool(depth, work int, visited *atomic.Int64) stealTask {
+	return func(w *stealWorker) {
+		spin(work)
+		visited.Add(1)
+		if depth > 0 {
+			w.spawn(treeOnStealPool(depth-1, work, visited))
+			w.spawn(treeOnStealPool(depth-1, work, visited))
+		}
+	}
+}
+
+// benchResult is the best of several timed runs of one workload on one pool
+type benchResult struct {
+	pool   string
+	depth  int
+	work   int
+	tasks  int
+	best   time.Duration
+	steals int64
+}
+
+// channelTreeRound times one walk of the tree on a fresh channel pool.
+func channelTreeRound(workers, depth, work int) time.Duration {
+	tasks := treeNodes(depth)
+	// the queue must hold the whole tree: a worker blocked in submit on a full queue
+	// can no longer drain it, and with every worker doing so the pool deadlocks.
+	// uninstrumented, like the work-stealing pool: the comparison is about scheduling only
+	pool := newUninstrumentedThreadPool(workers, tasks)
+	defer pool.close()
+	var visited atomic.Int64
+
+	start := time.Now()
+	pool.submit(treeOnChannelPool(pool, depth, work, &visited))
+	pool.waitCompletion()
+	elapsed := time.Since(start)
+
+	if int(visited.Load()) != tasks {
+		panic(fmt.Sprintf("channel pool visited %d of %d nodes", visited.Load(), tasks))
+	}
+	return elapsed
+}
+
+// stealTreeRound times one walk of the tree on a fresh work-stealing pool, and counts its steals.
+func stealTreeRound(workers, depth, work int) (time.Duration, int64) {
+	tasks := treeNodes(depth)
+	pool := newStealPool(workers)
+	defer pool.close()
+	var visited atomic.Int64
+
+	start := time.Now()
+	pool.submit(treeOnStealPool(depth, work, &visited))
+	pool.waitCompletion()
+	elapsed := time.Since(start)
+
+	if int(visited.Load()) != tasks {
+		panic(fmt.Sprintf("work-stealing pool visited %d of %d nodes", visited.Load(), tasks))
+	}
+	return elapsed, pool.steals.Load()
+}
+
+func benchChannelPool(workers, depth, work, rounds int) benchResult {
+	res := benchResult{pool: "channel", depth: depth, work: work, tasks: treeNodes(depth)}
+	for range rounds {
+		if elapsed := channelTreeRound(workers, depth, work); res.best == 0 || elapsed < res.best {
+			res.best = elapsed
+		}
+	}
+	return res
+}
+
+func benchStealPool(workers, depth, work, rounds int) benchResult {
+	res := benchResult{pool: "work-stealing", depth: depth, work: work, tasks: treeNodes(depth)}
+	for range rounds {
+		if elapsed, steals := stealTreeRound(workers, depth, work); res.best == 0 || elapsed < res.best {
+			res.best, res.steals = elapsed, steals
+		}
+	}
+	return res
+}
+
+// runBench compares the channel-based pool with the work-stealing pool on recursive workloads
+// of decreasing task granularity, reporting the best of a few rounds for each.
+// `go test -bench .` runs the same comparison as testing.B benchmarks.
+func runBench() {
+	workers := runtime.GOMAXPROCS(0)
+	const (
+		depth  = 16
+		rounds = 5
+	)
+	fmt.Printf("Recursive binary tree, depth %d (%d tasks), %d workers, best of %d rounds\n\n",
+		depth, treeNodes(depth), workers, rounds)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "pool\twork/task\ttotal\tper task\tsteals\t")
+	for _, work := range []int{10_000, 1_000, 100} {
+		for _, res := range []benchResult{
+			benchChannelPool(workers, depth, work, rounds),
+			benchStealPool(workers, depth, work, rounds),
+		} {
+			steals := "-"
+			if res.pool == "work-stealing" {
+				steals = fmt.Sprint(res.steals)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t\n",
+				res.pool, res.work, res.best.Round(time.Microsecond),
+				(res.best / time.Duration(res.tasks)).String(), steals)
+		}
+	}
+	tw.Flush()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// BenchmarkRecursiveTree compares the uninstrumented channel pool with the work-stealing pool
+// on the recursive workload of runBench. ns/task is the wall time of a walk divided by its tasks.
+func BenchmarkRecursiveTree(b *testing.B) {
+	const depth = 12
+	workers := runtime.GOMAXPROCS(0)
+	for _, work := range []int{10_000, 1_000, 100} {
+		b.Run(fmt.Sprintf("channel/work=%d", work), func(b *testing.B) {
+			var total time.Duration
+			for b.Loop() {
+				total += channelTreeRound(workers, depth, work)
+			}
+			b.ReportMetric(float64(total)/float64(b.N*treeNodes(depth)), "ns/task")
+		})
+		b.Run(fmt.Sprintf("work-stealing/work=%d", work), func(b *testing.B) {
+			var total time.Duration
+			var steals int64
+			for b.Loop() {
+				elapsed, n := stealTreeRound(workers, depth, work)
+				total += elapsed
+				steals += n
+			}
+			b.ReportMetric(float64(total)/float64(b.N*treeNodes(depth)), "ns/task")
+			b.ReportMetric(float64(steals)/float64(b.N), "steals/op")
+		})
+	}
+}
@@ -0,0 +1,3 @@
+module threadpool
+
+go 1.25.5
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// cpuWaster simulates a CPU-bound task by sleeping for a fixed duration
+func cpuWaster(i int) task {
+	return func(workerName string) {
+		fmt.Printf("%s doing %d work\n", workerName, i)
+		time.Sleep(3 * time.Second)
+	}
+}
+
+func runPool(metricsAddr string) {
+	// creates a thread pool with 5 workers and a queue size of 5
+	pool := newThreadPool(5, 5)
+
+	if metricsAddr != "" {
+		srv, err := serveMetrics(metricsAddr, pool)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Main: metrics server error: %v\n", err)
+			os.Exit(1)
+		}
+		defer srv.Close()
+		fmt.Printf("Metrics at http://%s/metrics and http://%s/debug/vars\n", metricsAddr, metricsAddr)
+	}
+
+	for i := range 20 { // add 20 tasks to the pool
+		pool.submit(cpuWaster(i))
+	}
+
+	fmt.Println("All work requests sent")
+	pool.waitCompletion()
+	fmt.Println("All work complete")
+	pool.close()
+
+	pool.Stats().Print(os.Stdout)
+}
+
+func runSchedule() {
+	pool := newThreadPool(2, 2)
+	sched := newScheduledExecutor(pool)
+	start := time.Now()
+
+	// elapsed prefixes output with the time since start, to make the cadence visible
+	elapsed := func() string { return fmt.Sprintf("[%4.1fs]", time.Since(start).Seconds()) }
+
+	sched.Schedule(2*time.Second, func(workerName string) {
+		fmt.Printf("%s %s one-shot task, scheduled 2s after start\n", elapsed(), workerName)
+	})
+	rate := sched.ScheduleAtFixedRate(0, 1*time.Second, func(workerName string) {
+		fmt.Printf("%s %s fixed rate tick (every 1s, start to start)\n", elapsed(), workerName)
+	})
+	sched.ScheduleWithFixedDelay(500*time.Millisecond, 1*time.Second, func(workerName string) {
+		fmt.Printf("%s %s fixed delay tick (1s after previous run ended), working 700ms...\n", elapsed(), workerName)
+		time.Sleep(700 * time.Millisecond)
+	})
+
+	time.Sleep(5 * time.Second)
+	fmt.Printf("%s cancelling the fixed rate task\n", elapsed())
+	rate.Cancel()
+
+	time.Sleep(3 * time.Second)
+	fmt.Printf("%s shutting down the scheduler\n", elapsed())
+	sched.shutdown()
+	pool.waitCompletion()
+	pool.close()
+	fmt.Printf("%s all scheduled work complete\n", elapsed())
+}
+
+func main() {
+	metricsAddr := flag.String("metrics", "", "serve pool metrics on this loopback address, e.g. localhost:8080")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . [-metrics=addr] [pool|schedule|bench]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	mode := flag.Arg(0)
+	if mode == "" {
+		mode = "pool"
+	}
+
+	switch mode {
+	case "pool":
+		runPool(*metricsAddr)
+	case "schedule":
+		runSchedule()
+	case "bench":
+		runBench()
+	default:
+		fmt.Printf("Unknown mode %q. Use 'pool', 'schedule' or 'bench'.\n", mode)
+		os.Exit(1)
+	}
+}
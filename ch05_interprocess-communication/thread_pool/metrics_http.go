@@ -0,0 +1,98 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// expvar.Publish panics on a second call with the same name, so the variable is published once
+	// and reports whichever pool was served last.
+	publishOnce sync.Once
+	expvarPool  atomic.Pointer[threadPool]
+)
+
+// serveMetrics exposes the pool statistics over HTTP on a loopback address:
+//
+//	/debug/vars  expvar JSON (the "threadpool" variable)
+//	/metrics     Prometheus text exposition format
+//
+// The returned server is already serving; shut it down with Close.
+func serveMetrics(addr string, tp *threadPool) (*http.Server, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("metrics address %q is not a loopback address", addr)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	expvarPool.Store(tp)
+	publishOnce.Do(func() {
+		expvar.Publish("threadpool", expvar.Func(func() any { return expvarPool.Load().Stats() }))
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writePrometheus(w, tp.Stats())
+	})
+
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	return srv, nil
+}
+
+// writePrometheus renders a snapshot in the Prometheus text format.
+func writePrometheus(w io.Writer, s Stats) {
+	counter := func(name, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	gauge := func(name, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+	}
+	histogram := func(name, help string, h HistogramStats) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+		var cumulative int64
+		for i, n := range h.Buckets {
+			cumulative += n
+			le := "+Inf"
+			if i < len(histogramBounds) {
+				le = strconv.FormatFloat(histogramBounds[i].Seconds(), 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, le, cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, h.Sum.Seconds(), name, h.Count)
+	}
+
+	counter("threadpool_tasks_submitted_total", "Tasks submitted to the pool.", s.Submitted)
+	counter("threadpool_tasks_completed_total", "Tasks that returned normally.", s.Completed)
+	counter("threadpool_tasks_panicked_total", "Tasks that panicked and were recovered.", s.Panicked)
+	gauge("threadpool_tasks_queued", "Tasks waiting in the queue.", s.Queued)
+	gauge("threadpool_tasks_running", "Tasks currently executing.", s.Running)
+	histogram("threadpool_queue_wait_seconds", "Time tasks spent queued before a worker picked them up.", s.QueueWait)
+	histogram("threadpool_exec_seconds", "Time tasks spent executing.", s.ExecTime)
+
+	fmt.Fprintf(w, "# HELP threadpool_worker_busy_seconds Time each worker spent executing tasks.\n")
+	fmt.Fprintf(w, "# TYPE threadpool_worker_busy_seconds counter\n")
+	for _, ws := range s.Workers {
+		fmt.Fprintf(w, "threadpool_worker_busy_seconds{worker=%q} %g\n", ws.Name, ws.Busy.Seconds())
+	}
+	fmt.Fprintf(w, "# HELP threadpool_worker_utilization Fraction of pool uptime each worker spent busy.\n")
+	fmt.Fprintf(w, "# TYPE threadpool_worker_utilization gauge\n")
+	for _, ws := range s.Workers {
+		fmt.Fprintf(w, "threadpool_worker_utilization{worker=%q} %g\n", ws.Name, ws.Utilization)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// task represents a unit of work to be executed by a worker
+type task func(workerName string)
+
+// worker represents a single thread of execution in the thread pool
+type worker struct {
+	name  string          // for visibility in output
+	tasks <-chan task     // receive-only channel from the pool's queue
+	wg    *sync.WaitGroup // shared with the pool to track task completion
+	stats *workerStats    // per-worker utilization counters, nil for an uninstrumented pool
+}
+
+func NewWorker(name string, tasks <-chan task, wg *sync.WaitGroup, stats *workerStats) *worker {
+	return &worker{name: name, tasks: tasks, wg: wg, stats: stats}
+}
+
+// start begins the worker's task processing loop in a new goroutine/thread
+func (w *worker) start() {
+	go func() {
+		for task := range w.tasks {
+			if w.stats == nil {
+				task(w.name)
+				continue
+			}
+			begin := time.Now()
+			task(w.name)
+			w.stats.record(time.Since(begin))
+		}
+	}()
+}
+
+// threadPool manages a pool of worker threads to execute submitted tasks
+type threadPool struct {
+	tasks   chan task      // message queue
+	wg      sync.WaitGroup // completion tracker
+	once    sync.Once      // ensure close() is safe if called multiple times
+	metrics *poolMetrics   // counters and histograms, see Stats(); nil for an uninstrumented pool
+}
+
+func newThreadPool(numWorkers, queueSize int) *threadPool {
+	return newPool(numWorkers, queueSize, newPoolMetrics())
+}
+
+// newUninstrumentedThreadPool returns a pool that keeps no statistics,
+// so benchmarks measure the scheduling and not the bookkeeping.
+func newUninstrumentedThreadPool(numWorkers, queueSize int) *threadPool {
+	return newPool(numWorkers, queueSize, nil)
+}
+
+func newPool(numWorkers, queueSize int, metrics *poolMetrics) *threadPool {
+	if numWorkers <= 0 {
+		numWorkers = 1
+	}
+	if queueSize <= 0 {
+		queueSize = numWorkers
+	}
+
+	// creates and starts several worker threads
+	tp := &threadPool{tasks: make(chan task, queueSize), metrics: metrics}
+	for i := range numWorkers {
+		name := fmt.Sprintf("Thread-%d", i+1)
+		var stats *workerStats
+		if metrics != nil {
+			stats = metrics.addWorker(name)
+		}
+		NewWorker(name, tp.tasks, &tp.wg, stats).start()
+	}
+	return tp
+}
+
+// submit enqueues a task for execution
+func (tp *threadPool) submit(t task) {
+	tp.wg.Add(1)
+	m := tp.metrics
+	if m == nil {
+		tp.tasks <- func(workerName string) {
+			defer tp.wg.Done()
+			defer recoverTask(workerName)
+			t(workerName)
+		}
+		return
+	}
+	m.submitted.Add(1)
+	m.queued.Add(1)
+	enqueuedAt := time.Now()
+
+	// wrap the task so Done is always called even if it panics
+	tp.tasks <- func(workerName string) {
+		defer tp.wg.Done()
+
+		startedAt := time.Now()
+		m.queued.Add(-1)
+		m.running.Add(1)
+		m.queueWait.observe(startedAt.Sub(enqueuedAt))
+
+		// protect the worker from "task failure" (panic), akin to catching exceptions
+		// without recover, a panic would terminate the worker goroutine, shrinking the pool
+		// with recover, the worker can continue processing further tasks
+		defer func() {
+			m.running.Add(-1)
+			m.execTime.observe(time.Since(startedAt))
+			if r := recover(); r != nil {
+				m.panicked.Add(1)
+				fmt.Printf("%s recovered task panic: %v\n", workerName, r)
+				return
+			}
+			m.completed.Add(1)
+		}()
+
+		t(workerName)
+	}
+}
+
+// recoverTask keeps a panicking task from terminating the worker goroutine; it must be deferred.
+func recoverTask(workerName string) {
+	if r := recover(); r != nil {
+		fmt.Printf("%s recovered task panic: %v\n", workerName, r)
+	}
+}
+
+// waitCompletion blocks until all submitted tasks have completed
+func (tp *threadPool) waitCompletion() { tp.wg.Wait() }
+
+// close gracefully shuts down the thread pool by closing the task channel,
+// thus signaling workers to exit once all tasks are done and no new tasks will arrive
+func (tp *threadPool) close() { tp.once.Do(func() { close(tp.tasks) }) }
+
+// Stats returns a point-in-time snapshot of the pool's counters and histograms
+// (all zero for an uninstrumented pool)
+func (tp *threadPool) Stats() Stats {
+	if tp.metrics == nil {
+		return Stats{}
+	}
+	return tp.metrics.snapshot()
+}
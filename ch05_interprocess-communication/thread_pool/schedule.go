@@ -0,0 +1,213 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scheduledTask is a task waiting in the timer heap, and the cancellation handle given back to callers.
+type scheduledTask struct {
+	t         task
+	at        time.Time     // next time the task becomes due
+	period    time.Duration // 0 for one-shot tasks
+	fixedRate bool          // true: next run is at+period, false: next run is period after the last run ended
+	index     int           // position in the heap, -1 when not queued
+	cancelled atomic.Bool
+	exec      *scheduledExecutor
+}
+
+// Cancel prevents any future run of the task. A run that is already executing is not interrupted.
+// It reports whether this call cancelled the task, false if it was already cancelled.
+func (st *scheduledTask) Cancel() bool {
+	if !st.cancelled.CompareAndSwap(false, true) {
+		return false
+	}
+	st.exec.remove(st)
+	return true
+}
+
+// timerHeap is a min-heap of scheduled tasks ordered by due time (container/heap.Interface).
+type timerHeap []*scheduledTask
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *timerHeap) Push(x any) {
+	st := x.(*scheduledTask)
+	st.index = len(*h)
+	*h = append(*h, st)
+}
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	st := old[n-1]
+	old[n-1] = nil
+	st.index = -1
+	*h = old[:n-1]
+	return st
+}
+
+// scheduledExecutor runs delayed and periodic tasks on a thread pool.
+//
+// A single timer goroutine sleeps until the earliest task in the heap is due, then submits it to the pool.
+// Periodic tasks are re-queued only after a run finishes, so runs of the same task never overlap.
+// A periodic task that panics is not re-queued, its later runs are suppressed.
+type scheduledExecutor struct {
+	pool *threadPool
+
+	mu     sync.Mutex // guards queue and closed
+	queue  timerHeap
+	closed bool
+
+	wake    chan struct{} // nudges the timer goroutine when the earliest deadline may have changed
+	done    chan struct{} // closed by shutdown
+	stopped chan struct{} // closed when the timer goroutine has exited
+	once    sync.Once
+}
+
+func newScheduledExecutor(pool *threadPool) *scheduledExecutor {
+	e := &scheduledExecutor{
+		pool:    pool,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+// Schedule runs t once after delay.
+func (e *scheduledExecutor) Schedule(delay time.Duration, t task) *scheduledTask {
+	return e.push(e.newTask(t, delay, 0, false))
+}
+
+// ScheduleAtFixedRate runs t after initialDelay and then every period, measured from start to start.
+// If a run takes longer than period, the next one starts late rather than concurrently.
+func (e *scheduledExecutor) ScheduleAtFixedRate(initialDelay, period time.Duration, t task) *scheduledTask {
+	if period <= 0 {
+		panic("ScheduleAtFixedRate: period must be positive")
+	}
+	return e.push(e.newTask(t, initialDelay, period, true))
+}
+
+// ScheduleWithFixedDelay runs t after initialDelay and then again delay after each run finishes.
+func (e *scheduledExecutor) ScheduleWithFixedDelay(initialDelay, delay time.Duration, t task) *scheduledTask {
+	if delay <= 0 {
+		panic("ScheduleWithFixedDelay: delay must be positive")
+	}
+	return e.push(e.newTask(t, initialDelay, delay, false))
+}
+
+// shutdown stops the timer goroutine and drops every pending task.
+// Runs already submitted to the pool still complete; close the pool afterwards as usual.
+// It returns once the timer goroutine will no longer submit to the pool.
+func (e *scheduledExecutor) shutdown() {
+	e.once.Do(func() {
+		e.mu.Lock()
+		e.closed = true
+		for _, st := range e.queue {
+			st.cancelled.Store(true)
+			st.index = -1
+		}
+		e.queue = nil
+		e.mu.Unlock()
+		close(e.done)
+	})
+	<-e.stopped
+}
+
+func (e *scheduledExecutor) newTask(t task, delay, period time.Duration, fixedRate bool) *scheduledTask {
+	return &scheduledTask{t: t, at: time.Now().Add(delay), period: period, fixedRate: fixedRate, index: -1, exec: e}
+}
+
+// push queues st unless the executor is shut down or st was cancelled, in which case it is dropped.
+func (e *scheduledExecutor) push(st *scheduledTask) *scheduledTask {
+	e.mu.Lock()
+	if e.closed || st.cancelled.Load() {
+		st.cancelled.Store(true)
+		e.mu.Unlock()
+		return st
+	}
+	heap.Push(&e.queue, st)
+	e.mu.Unlock()
+
+	e.signal()
+	return st
+}
+
+func (e *scheduledExecutor) remove(st *scheduledTask) {
+	e.mu.Lock()
+	if st.index >= 0 {
+		heap.Remove(&e.queue, st.index)
+	}
+	e.mu.Unlock()
+	e.signal()
+}
+
+// signal wakes the timer goroutine without blocking, one pending wake-up is enough.
+func (e *scheduledExecutor) signal() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (e *scheduledExecutor) loop() {
+	defer close(e.stopped)
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		e.mu.Lock()
+		var due *scheduledTask
+		if len(e.queue) > 0 {
+			if d := time.Until(e.queue[0].at); d <= 0 {
+				due = heap.Pop(&e.queue).(*scheduledTask)
+			} else {
+				timer.Reset(d)
+			}
+		}
+		e.mu.Unlock()
+
+		if due != nil {
+			e.run(due) // may block while the pool's queue is full, delaying later timers
+			continue
+		}
+
+		select {
+		case <-timer.C:
+		case <-e.wake:
+			timer.Stop()
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// run hands a due task to the pool and, for periodic tasks, re-queues it once the run is over.
+func (e *scheduledExecutor) run(st *scheduledTask) {
+	e.pool.submit(func(workerName string) {
+		if st.cancelled.Load() {
+			return
+		}
+		st.t(workerName) // a panic here skips the re-queue below
+
+		if st.period == 0 {
+			return
+		}
+		if st.fixedRate {
+			st.at = st.at.Add(st.period)
+		} else {
+			st.at = time.Now().Add(st.period)
+		}
+		e.push(st)
+	})
+}
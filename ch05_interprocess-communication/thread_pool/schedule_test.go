@@ -0,0 +1,236 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestExecutor returns an executor on a fresh pool, both shut down when the test ends.
+func newTestExecutor(t *testing.T, workers int) *scheduledExecutor {
+	t.Helper()
+	pool := newUninstrumentedThreadPool(workers, workers)
+	e := newScheduledExecutor(pool)
+	t.Cleanup(func() {
+		e.shutdown()
+		pool.close()
+	})
+	return e
+}
+
+// runLog records when each run of a task started.
+type runLog struct {
+	mu     sync.Mutex
+	starts []time.Time
+	ran    chan struct{} // one value per run, never blocks
+}
+
+func newRunLog() *runLog { return &runLog{ran: make(chan struct{}, 1000)} }
+
+func (l *runLog) task(string) {
+	l.mu.Lock()
+	l.starts = append(l.starts, time.Now())
+	l.mu.Unlock()
+	l.ran <- struct{}{}
+}
+
+func (l *runLog) runs() []time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]time.Time(nil), l.starts...)
+}
+
+// waitRuns fails the test unless n more runs happen within a second.
+func (l *runLog) waitRuns(t *testing.T, n int) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for range n {
+		select {
+		case <-l.ran:
+		case <-timeout:
+			t.Fatalf("only %d runs within a second", len(l.runs()))
+		}
+	}
+}
+
+// expectNoRun fails the test if l runs within d.
+func (l *runLog) expectNoRun(t *testing.T, d time.Duration) {
+	t.Helper()
+	select {
+	case <-l.ran:
+		t.Fatal("the task ran again")
+	case <-time.After(d):
+	}
+}
+
+func TestScheduleRunsOnceAfterDelay(t *testing.T) {
+	e := newTestExecutor(t, 2)
+	l := newRunLog()
+	start := time.Now()
+	e.Schedule(30*time.Millisecond, l.task)
+
+	l.waitRuns(t, 1)
+	if d := l.runs()[0].Sub(start); d < 30*time.Millisecond {
+		t.Errorf("ran after %s, before its 30ms delay", d)
+	}
+	l.expectNoRun(t, 50*time.Millisecond)
+}
+
+func TestScheduleRunsInDueOrder(t *testing.T) {
+	e := newTestExecutor(t, 1)
+	var mu sync.Mutex
+	var order []int
+	done := make(chan struct{}, 3)
+	for _, ms := range []int{60, 20, 40} {
+		e.Schedule(time.Duration(ms)*time.Millisecond, func(string) {
+			mu.Lock()
+			order = append(order, ms)
+			mu.Unlock()
+			done <- struct{}{}
+		})
+	}
+	for range 3 {
+		<-done
+	}
+	if order[0] != 20 || order[1] != 40 || order[2] != 60 {
+		t.Errorf("ran in order %v, want [20 40 60]", order)
+	}
+}
+
+func TestScheduleAtFixedRate(t *testing.T) {
+	e := newTestExecutor(t, 2)
+	l := newRunLog()
+	const period = 20 * time.Millisecond
+	start := time.Now()
+	st := e.ScheduleAtFixedRate(10*time.Millisecond, period, l.task)
+
+	l.waitRuns(t, 4)
+	st.Cancel()
+	// the nth run is due at start+10ms+n*period, measured from start to start, so it never runs early
+	for i, at := range l.runs()[:4] {
+		if due := start.Add(10*time.Millisecond + time.Duration(i)*period); at.Before(due) {
+			t.Errorf("run %d started %s before it was due", i, due.Sub(at))
+		}
+	}
+}
+
+func TestScheduleWithFixedDelay(t *testing.T) {
+	e := newTestExecutor(t, 2)
+	const delay, work = 20 * time.Millisecond, 10 * time.Millisecond
+	var mu sync.Mutex
+	var starts, ends []time.Time
+	ran := make(chan struct{}, 100)
+	st := e.ScheduleWithFixedDelay(0, delay, func(string) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		time.Sleep(work)
+		mu.Lock()
+		ends = append(ends, time.Now())
+		mu.Unlock()
+		ran <- struct{}{}
+	})
+	for range 3 {
+		<-ran
+	}
+	st.Cancel()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 1; i < 3; i++ {
+		if gap := starts[i].Sub(ends[i-1]); gap < delay {
+			t.Errorf("run %d started %s after the previous one ended, want at least %s", i, gap, delay)
+		}
+	}
+}
+
+// A run longer than the period delays the next one instead of overlapping it, even with idle workers.
+func TestPeriodicRunsNeverOverlap(t *testing.T) {
+	e := newTestExecutor(t, 4)
+	var running, maxRunning atomic.Int32
+	l := newRunLog()
+	st := e.ScheduleAtFixedRate(0, time.Millisecond, func(name string) {
+		n := running.Add(1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		l.task(name)
+	})
+	l.waitRuns(t, 5)
+	st.Cancel()
+	if n := maxRunning.Load(); n != 1 {
+		t.Errorf("%d runs of the same task at once, want 1", n)
+	}
+}
+
+func TestCancelBeforeRun(t *testing.T) {
+	e := newTestExecutor(t, 2)
+	l := newRunLog()
+	st := e.Schedule(30*time.Millisecond, l.task)
+	if !st.Cancel() {
+		t.Fatal("first Cancel reported the task as already cancelled")
+	}
+	if st.Cancel() {
+		t.Error("second Cancel reported that it cancelled the task")
+	}
+	l.expectNoRun(t, 80*time.Millisecond)
+}
+
+func TestCancelDuringRun(t *testing.T) {
+	e := newTestExecutor(t, 2)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var runs atomic.Int32
+	st := e.ScheduleAtFixedRate(0, 5*time.Millisecond, func(string) {
+		if runs.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+	})
+
+	<-started
+	if !st.Cancel() {
+		t.Fatal("Cancel during a run reported the task as already cancelled")
+	}
+	close(release) // the run in progress finishes, and must not queue the next one
+	time.Sleep(50 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("%d runs, want only the one that was executing when cancelled", n)
+	}
+}
+
+func TestPanickingPeriodicTaskStops(t *testing.T) {
+	e := newTestExecutor(t, 2)
+	var runs atomic.Int32
+	e.ScheduleAtFixedRate(0, 5*time.Millisecond, func(string) {
+		runs.Add(1)
+		panic("boom")
+	})
+	time.Sleep(50 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("%d runs, want the run that panicked and no more", n)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	pool := newUninstrumentedThreadPool(2, 2)
+	defer pool.close()
+	e := newScheduledExecutor(pool)
+	l := newRunLog()
+	once := e.Schedule(30*time.Millisecond, l.task)
+	periodic := e.ScheduleAtFixedRate(30*time.Millisecond, 10*time.Millisecond, l.task)
+
+	e.shutdown()
+	e.shutdown() // safe to call again
+	if once.Cancel() || periodic.Cancel() {
+		t.Error("a pending task was still cancellable after shutdown")
+	}
+	late := e.Schedule(0, l.task)
+	if late.Cancel() {
+		t.Error("a task scheduled after shutdown was not dropped")
+	}
+	l.expectNoRun(t, 80*time.Millisecond)
+}
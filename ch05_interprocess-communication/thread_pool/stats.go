@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// histogramBounds are the upper bounds of the latency buckets, the last bucket catches everything above.
+// They are deliberately coarse: the example tasks take milliseconds to seconds.
+var histogramBounds = []time.Duration{
+	1 * time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	5 * time.Second,
+	30 * time.Second,
+}
+
+// histogram is a fixed-bucket latency histogram safe for concurrent observation.
+type histogram struct {
+	buckets []atomic.Int64 // len(histogramBounds)+1, the extra one is +Inf
+	count   atomic.Int64
+	sum     atomic.Int64 // nanoseconds
+}
+
+func newHistogram() *histogram {
+	return &histogram{buckets: make([]atomic.Int64, len(histogramBounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(histogramBounds) && d > histogramBounds[i] {
+		i++
+	}
+	h.buckets[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *histogram) snapshot() HistogramStats {
+	s := HistogramStats{Buckets: make([]int64, len(h.buckets))}
+	for i := range h.buckets {
+		s.Buckets[i] = h.buckets[i].Load()
+	}
+	s.Count = h.count.Load()
+	s.Sum = time.Duration(h.sum.Load())
+	return s
+}
+
+// workerStats tracks how long a single worker spent executing tasks.
+type workerStats struct {
+	name  string
+	tasks atomic.Int64
+	busy  atomic.Int64 // nanoseconds
+}
+
+func (w *workerStats) record(d time.Duration) {
+	w.tasks.Add(1)
+	w.busy.Add(int64(d))
+}
+
+// poolMetrics is the live, mutable side of the pool statistics.
+// Counters are atomics so workers never contend on a lock just to count.
+type poolMetrics struct {
+	startedAt time.Time
+
+	submitted atomic.Int64
+	queued    atomic.Int64 // gauge: submitted but not yet picked up
+	running   atomic.Int64 // gauge: currently executing
+	completed atomic.Int64
+	panicked  atomic.Int64
+
+	queueWait *histogram
+	execTime  *histogram
+
+	mu      sync.Mutex // guards the workers slice, not the stats inside it
+	workers []*workerStats
+}
+
+func newPoolMetrics() *poolMetrics {
+	return &poolMetrics{startedAt: time.Now(), queueWait: newHistogram(), execTime: newHistogram()}
+}
+
+func (m *poolMetrics) addWorker(name string) *workerStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ws := &workerStats{name: name}
+	m.workers = append(m.workers, ws)
+	return ws
+}
+
+func (m *poolMetrics) snapshot() Stats {
+	uptime := time.Since(m.startedAt)
+	s := Stats{
+		Uptime:    uptime,
+		Submitted: m.submitted.Load(),
+		Queued:    m.queued.Load(),
+		Running:   m.running.Load(),
+		Completed: m.completed.Load(),
+		Panicked:  m.panicked.Load(),
+		QueueWait: m.queueWait.snapshot(),
+		ExecTime:  m.execTime.snapshot(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.workers {
+		busy := time.Duration(w.busy.Load())
+		s.Workers = append(s.Workers, WorkerStats{
+			Name:        w.name,
+			Tasks:       w.tasks.Load(),
+			Busy:        busy,
+			Utilization: float64(busy) / float64(uptime),
+		})
+	}
+	return s
+}
+
+// HistogramStats is a snapshot of a histogram.
+// Buckets[i] counts observations <= histogramBounds[i], the last entry counts the rest (+Inf).
+type HistogramStats struct {
+	Buckets []int64
+	Count   int64
+	Sum     time.Duration
+}
+
+// Mean returns the average observed duration, or zero when nothing was observed.
+func (h HistogramStats) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// WorkerStats is a snapshot of one worker's utilization.
+type WorkerStats struct {
+	Name        string
+	Tasks       int64
+	Busy        time.Duration
+	Utilization float64 // fraction of pool uptime spent running tasks
+}
+
+// Stats is a point-in-time snapshot of the pool, safe to keep and print.
+type Stats struct {
+	Uptime    time.Duration
+	Submitted int64
+	Queued    int64
+	Running   int64
+	Completed int64
+	Panicked  int64
+	QueueWait HistogramStats
+	ExecTime  HistogramStats
+	Workers   []WorkerStats
+}
+
+// Print writes a human-readable summary of the snapshot.
+func (s Stats) Print(w io.Writer) {
+	fmt.Fprintf(w, "Pool stats after %s:\n", s.Uptime.Round(time.Millisecond))
+	fmt.Fprintf(w, "  submitted=%d queued=%d running=%d completed=%d panicked=%d\n",
+		s.Submitted, s.Queued, s.Running, s.Completed, s.Panicked)
+	fmt.Fprintf(w, "  queue wait: mean=%s over %d tasks\n", s.QueueWait.Mean().Round(time.Millisecond), s.QueueWait.Count)
+	fmt.Fprintf(w, "  exec time:  mean=%s over %d tasks\n", s.ExecTime.Mean().Round(time.Millisecond), s.ExecTime.Count)
+	for _, ws := range s.Workers {
+		fmt.Fprintf(w, "  %s: %d tasks, busy %s (%.0f%%)\n",
+			ws.Name, ws.Tasks, ws.Busy.Round(time.Millisecond), ws.Utilization*100)
+	}
+}
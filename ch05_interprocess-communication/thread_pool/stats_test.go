@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogramSnapshot(t *testing.T) {
+	h := newHistogram()
+	for _, d := range []time.Duration{
+		500 * time.Microsecond, // <= 1ms
+		time.Millisecond,       // bounds are inclusive
+		5 * time.Millisecond,   // <= 10ms
+		2 * time.Second,        // <= 5s
+		time.Minute,            // +Inf
+	} {
+		h.observe(d)
+	}
+
+	s := h.snapshot()
+	if want := []int64{2, 1, 0, 0, 0, 1, 0, 1}; !slices.Equal(s.Buckets, want) {
+		t.Errorf("buckets = %v, want %v", s.Buckets, want)
+	}
+	if s.Count != 5 {
+		t.Errorf("count = %d, want 5", s.Count)
+	}
+	if want := 62*time.Second + 6500*time.Microsecond; s.Sum != want {
+		t.Errorf("sum = %s, want %s", s.Sum, want)
+	}
+	if want := s.Sum / 5; s.Mean() != want {
+		t.Errorf("mean = %s, want %s", s.Mean(), want)
+	}
+	if (HistogramStats{}).Mean() != 0 {
+		t.Error("mean of an empty histogram is not zero")
+	}
+}
+
+// waitWorkerTasks waits for the workers to record n tasks: a worker counts a task after the task
+// has already called Done, so waitCompletion may return a moment before.
+func waitWorkerTasks(t *testing.T, tp *threadPool, n int64) Stats {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s := tp.Stats()
+		var total int64
+		for _, w := range s.Workers {
+			total += w.Tasks
+		}
+		if total == n {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("workers recorded %d tasks, want %d", total, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	tp := newThreadPool(2, 4)
+	defer tp.close()
+	for i := range 5 {
+		tp.submit(func(string) {
+			time.Sleep(2 * time.Millisecond)
+			if i == 3 {
+				panic("task 3 fails")
+			}
+		})
+	}
+	tp.waitCompletion()
+	s := waitWorkerTasks(t, tp, 5)
+
+	if s.Submitted != 5 || s.Completed != 4 || s.Panicked != 1 {
+		t.Errorf("submitted=%d completed=%d panicked=%d, want 5, 4 and 1", s.Submitted, s.Completed, s.Panicked)
+	}
+	if s.Queued != 0 || s.Running != 0 {
+		t.Errorf("queued=%d running=%d after waitCompletion, want 0 and 0", s.Queued, s.Running)
+	}
+	if s.QueueWait.Count != 5 || s.ExecTime.Count != 5 {
+		t.Errorf("histogram counts: queue wait %d, exec %d, want 5 each", s.QueueWait.Count, s.ExecTime.Count)
+	}
+	if s.ExecTime.Mean() < 2*time.Millisecond {
+		t.Errorf("mean exec time %s, want at least the 2ms every task sleeps", s.ExecTime.Mean())
+	}
+	if len(s.Workers) != 2 {
+		t.Fatalf("%d workers, want 2", len(s.Workers))
+	}
+	for _, w := range s.Workers {
+		if w.Utilization < 0 || w.Utilization > 1 {
+			t.Errorf("%s utilization %g, want a fraction", w.Name, w.Utilization)
+		}
+	}
+}
+
+func TestUninstrumentedPoolStats(t *testing.T) {
+	tp := newUninstrumentedThreadPool(1, 1)
+	defer tp.close()
+	tp.submit(func(string) {})
+	tp.waitCompletion()
+	if s := tp.Stats(); s.Submitted != 0 || s.Workers != nil {
+		t.Errorf("uninstrumented pool reports %+v, want zero stats", s)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	s := Stats{
+		Submitted: 3, Completed: 2, Panicked: 1, Queued: 4, Running: 1,
+		ExecTime:  HistogramStats{Buckets: []int64{1, 1, 0, 0, 0, 0, 0, 1}, Count: 3, Sum: 1500 * time.Millisecond},
+		QueueWait: HistogramStats{Buckets: make([]int64, len(histogramBounds)+1)},
+		Workers:   []WorkerStats{{Name: "Thread-1", Busy: 1500 * time.Millisecond, Utilization: 0.25}},
+	}
+	var b strings.Builder
+	writePrometheus(&b, s)
+	out := b.String()
+
+	for _, line := range []string{
+		"# TYPE threadpool_tasks_submitted_total counter",
+		"threadpool_tasks_submitted_total 3",
+		"threadpool_tasks_completed_total 2",
+		"threadpool_tasks_panicked_total 1",
+		"# TYPE threadpool_tasks_queued gauge",
+		"threadpool_tasks_queued 4",
+		"threadpool_tasks_running 1",
+		"# TYPE threadpool_exec_seconds histogram",
+		`threadpool_exec_seconds_bucket{le="0.001"} 1`, // buckets are cumulative
+		`threadpool_exec_seconds_bucket{le="0.01"} 2`,
+		`threadpool_exec_seconds_bucket{le="30"} 2`,
+		`threadpool_exec_seconds_bucket{le="+Inf"} 3`,
+		"threadpool_exec_seconds_sum 1.5",
+		"threadpool_exec_seconds_count 3",
+		`threadpool_queue_wait_seconds_bucket{le="+Inf"} 0`,
+		`threadpool_worker_busy_seconds{worker="Thread-1"} 1.5`,
+		`threadpool_worker_utilization{worker="Thread-1"} 0.25`,
+	} {
+		if !slices.Contains(strings.Split(out, "\n"), line) {
+			t.Errorf("missing line %q in:\n%s", line, out)
+		}
+	}
+}
+
+// freeAddr returns a loopback address with a port that was free a moment ago.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func get(t *testing.T, url string) (string, http.Header) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", url, resp.Status)
+	}
+	return string(b), resp.Header
+}
+
+func TestServeMetrics(t *testing.T) {
+	if _, err := serveMetrics("192.0.2.1:0", nil); err == nil {
+		t.Error("serveMetrics accepted an address that is not loopback")
+	}
+
+	// serving a second pool replaces the first one in expvar instead of publishing the name twice, which panics
+	for _, tasks := range []int{2, 3} {
+		tp := newThreadPool(1, 1)
+		for range tasks {
+			tp.submit(func(string) {})
+		}
+		tp.waitCompletion()
+
+		addr := freeAddr(t)
+		srv, err := serveMetrics(addr, tp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := get(t, "http://"+addr+"/debug/vars")
+		var vars struct{ Threadpool Stats }
+		if err := json.Unmarshal([]byte(body), &vars); err != nil {
+			t.Fatalf("/debug/vars: %v", err)
+		}
+		if vars.Threadpool.Submitted != int64(tasks) {
+			t.Errorf("/debug/vars: threadpool.Submitted = %d, want %d", vars.Threadpool.Submitted, tasks)
+		}
+
+		body, header := get(t, "http://"+addr+"/metrics")
+		if ct := header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("/metrics content type %q", ct)
+		}
+		if want := fmt.Sprintf("threadpool_tasks_submitted_total %d\n", tasks); !strings.Contains(body, want) {
+			t.Errorf("/metrics does not contain %q:\n%s", want, body)
+		}
+
+		srv.Close()
+		tp.close()
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// stealTask is a unit of work for the work-stealing pool.
+// It receives the worker running it so it can spawn subtasks onto that worker's deque.
+type stealTask func(w *stealWorker)
+
+// deque is a double-ended task queue owned by one worker.
+//
+// The owner pushes and pops at the bottom (LIFO, cache-friendly for recursive work),
+// thieves take from the top (FIFO, the oldest and usually largest pieces of work).
+// A per-deque mutex keeps it simple: the owner only contends with the occasional thief,
+// never with every other worker as on the shared channel of threadPool.
+type deque struct {
+	mu    sync.Mutex
+	tasks []stealTask
+}
+
+func (d *deque) pushBottom(t stealTask) {
+	d.mu.Lock()
+	d.tasks = append(d.tasks, t)
+	d.mu.Unlock()
+}
+
+func (d *deque) popBottom() stealTask {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.tasks)
+	if n == 0 {
+		return nil
+	}
+	t := d.tasks[n-1]
+	d.tasks[n-1] = nil
+	d.tasks = d.tasks[:n-1]
+	return t
+}
+
+func (d *deque) stealTop() stealTask {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.tasks) == 0 {
+		return nil
+	}
+	t := d.tasks[0]
+	d.tasks[0] = nil
+	d.tasks = d.tasks[1:]
+	return t
+}
+
+// stealWorker is a worker with its own deque.
+type stealWorker struct {
+	name  string
+	local deque
+	pool  *stealPool
+}
+
+// spawn queues a subtask on this worker's own deque, where idle workers may steal it.
+func (w *stealWorker) spawn(t stealTask) { w.pool.push(w, t) }
+
+// stealPool is a work-stealing alternative to threadPool.
+// Each worker drains its own deque first and only steals from others when it runs dry.
+type stealPool struct {
+	workers []*stealWorker
+	wg      sync.WaitGroup // completion tracker
+	next    atomic.Uint64  // round-robin cursor for external submissions
+	steals  atomic.Int64   // number of successful steals, for the benchmark
+
+	// idle workers park on cond; pending and idle let push skip the lock when nobody sleeps
+	pending atomic.Int64 // tasks sitting in any deque, may dip below zero while a push is in flight
+	idle    atomic.Int32 // workers parked or about to park
+	mu      sync.Mutex
+	cond    *sync.Cond
+	closed  bool
+}
+
+func newStealPool(numWorkers int) *stealPool {
+	if numWorkers <= 0 {
+		numWorkers = 1
+	}
+
+	p := &stealPool{}
+	p.cond = sync.NewCond(&p.mu)
+	for i := range numWorkers {
+		p.workers = append(p.workers, &stealWorker{name: fmt.Sprintf("Thread-%d", i+1), pool: p})
+	}
+	for _, w := range p.workers {
+		go w.run()
+	}
+	return p
+}
+
+// submit queues a task from outside the pool, spreading submissions over the workers' deques.
+func (p *stealPool) submit(t stealTask) {
+	w := p.workers[p.next.Add(1)%uint64(len(p.workers))]
+	p.push(w, t)
+}
+
+func (p *stealPool) push(w *stealWorker, t stealTask) {
+	p.wg.Add(1)
+	w.local.pushBottom(t)
+	p.pending.Add(1)
+
+	// a worker increments idle before re-checking pending, so one of the two sides always sees the other
+	if p.idle.Load() > 0 {
+		p.mu.Lock()
+		p.cond.Signal()
+		p.mu.Unlock()
+	}
+}
+
+// waitCompletion blocks until all submitted and spawned tasks have completed
+func (p *stealPool) waitCompletion() { p.wg.Wait() }
+
+// close lets the workers exit once every deque is empty
+func (p *stealPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+func (w *stealWorker) run() {
+	p := w.pool
+	for {
+		if t := w.find(); t != nil {
+			w.execute(t)
+			continue
+		}
+
+		// nothing to do anywhere: park until a push signals new work
+		p.mu.Lock()
+		p.idle.Add(1)
+		for p.pending.Load() <= 0 && !p.closed {
+			p.cond.Wait()
+		}
+		p.idle.Add(-1)
+		done := p.closed && p.pending.Load() <= 0
+		p.mu.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
+// find returns the next task: own deque first, then a steal from a random victim.
+func (w *stealWorker) find() stealTask {
+	p := w.pool
+	if t := w.local.popBottom(); t != nil {
+		p.pending.Add(-1)
+		return t
+	}
+
+	n := len(p.workers)
+	start := rand.IntN(n)
+	for i := range n {
+		victim := p.workers[(start+i)%n]
+		if victim == w {
+			continue
+		}
+		if t := victim.local.stealTop(); t != nil {
+			p.pending.Add(-1)
+			p.steals.Add(1)
+			return t
+		}
+	}
+	return nil
+}
+
+func (w *stealWorker) execute(t stealTask) {
+	defer w.pool.wg.Done()
+
+	// same policy as threadPool: a panicking task must not take the worker down with it
+	defer recoverTask(w.name)
+
+	t(w)
+}
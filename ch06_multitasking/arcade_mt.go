@@ -2,78 +2,82 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
 )
 
 // timeSlice defines how often the scheduler switches between tasks, not the game logic cadence.
-const timeSlice = 500 * time.Millisecond
+// Each slice goes to one task, so it is short enough for every task to get its turn within Delay.
+const timeSlice = 100 * time.Millisecond
 
-// readInputBlockingInto reads stdin lines into the provided string pointer.
+// readInputLines sends the lines of in (stdin) into cmds, and closes it when in ends or fails.
 //
 // This is a dedicated blocking goroutine (I/O worker) so that the main scheduler loop never blocks on input.
-func readInputBlockingInto(pending *string) {
-	r := bufio.NewReader(os.Stdin)
+// It never touches the game state: the scheduler owns it, and the commands reach it through the channel.
+func readInputLines(in io.Reader, cmds chan<- string) {
+	defer close(cmds)
+	r := bufio.NewReader(in)
 	for {
-		if isGameOver {
-			return
-		}
 		line, err := r.ReadString('\n')
 		if err != nil {
-			isGameOver = true
-			gameOverMsg = "input error"
 			return
 		}
-		*pending = strings.TrimSpace(line)
+		cmds <- strings.TrimSpace(line)
 	}
 }
 
-func runMT() {
-	var pending string
+// runMT reads whole lines from stdin, or the decoded key presses from keys if it is not nil.
+// Cancelling ctx ends the game, as if the player had quit.
+func runMT(ctx context.Context, sched Scheduler, tracePath string, keys <-chan string, seed int64, brains []GhostBrain) {
+	cmds := keys
+	if cmds == nil {
+		lines := make(chan string, 16)
+		// blocking input runs "in the background" so the scheduler loop never blocks on stdin
+		go readInputLines(os.Stdin, lines)
+		cmds = lines
+	}
 
-	// blocking input runs "in the background" so the scheduler loop never blocks on stdin
-	go readInputBlockingInto(&pending)
+	world := newWorldTask(brains, seed)
+	state := world.state
+	input := &InputTask{src: NewChannelSource(cmds), state: state}
+	render := &RenderTask{state: state, screen: terminalScreen{os.Stdout}}
 
-	input := &InputTask{src: NewSharedBufferSource(&pending)}
-	world := &WorldTask{}
-	render := &RenderTask{}
-	tasks := []StepTask{input, world, render}
+	trace := newTraceRecorder(time.Now())
+	d, end := playMT(ctx, sched, realClock{}, state, []StepTask{input, world, render}, trace)
 
-	// track per-task next eligible run time based on task.Period()
-	nextRun := make(map[string]time.Time, len(tasks))
-	now := time.Now()
-	for _, t := range tasks {
-		nextRun[t.Name()] = now
+	// one final render, then wait for Enter (any key in raw mode): the next command from the input goroutine
+	render.Step(end, false)
+	select {
+	case <-cmds:
+	case <-ctx.Done():
 	}
+	d.printStats(os.Stdout)
+	fmt.Printf("Seed: %d\n", seed)
+	exportTrace(trace, tracePath, end)
+}
 
-	ticker := time.NewTicker(timeSlice)
-	defer ticker.Stop()
-	for now = range ticker.C {
-		// "scheduler loop": interleaves tasks, granting each a slice when eligible
-		for _, t := range tasks {
-			if isGameOver {
-				// one final render, then wait for Enter
-				(&RenderTask{}).Step(now, false)
-				_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
-				return
-			}
-
-			nr := nextRun[t.Name()]
-			if now.Before(nr) {
-				continue // not yet eligible
-			}
-
-			// in multitasking mode, Step must never block
-			t.Step(now, false)
+// playMT runs the scheduler loop until the game is over or ctx is cancelled, and returns the dispatcher
+// with its statistics and the time the game ended.
+func playMT(ctx context.Context, sched Scheduler, clock Clock, state *GameState, tasks []StepTask, trace *traceRecorder) (*dispatcher, time.Time) {
+	// the dispatcher tracks each task's next eligible run time based on task.Period()
+	d := newDispatcher(sched, tasks, clock, trace)
 
-			// update nextRun according to the task cadence
-			if p := t.Period(); p > 0 {
-				nextRun[t.Name()] = now.Add(p)
-			} else {
-				// period=0: eligible every scheduler tick
-				nextRun[t.Name()] = now
-			}
+	ticker := clock.NewTicker(timeSlice)
+	defer ticker.Stop()
+	for {
+		now := ticker.Wait()
+		if ctx.Err() != nil {
+			state.end("interrupted") // the loop owns the state, so it is the one to end the game
+		}
+		if state.IsGameOver {
+			d.finish(now)
+			return d, now
 		}
+		// "scheduler loop": interleaves tasks, granting each slice to the task the policy picks
+		d.runSlice(now)
 	}
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestInputGoroutineWithSchedulerLoop plays mt the way the terminal does: readInputLines receives
+// lines in its own goroutine while playMT runs the tasks, so run it with -race to check that
+// the two only share the channel.
+func TestInputGoroutineWithSchedulerLoop(t *testing.T) {
+	keys := []string{"d", "d", "d", "d", "d", "s", "s", "s", "x", "a", "a", "q"}
+	for _, name := range []string{"rr", "priority", "edf", "mlfq"} {
+		t.Run(name, func(t *testing.T) {
+			sched, err := newScheduler(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pr, pw := io.Pipe()
+			cmds := make(chan string, 16)
+			go readInputLines(pr, cmds)
+			go func() {
+				// the loop keeps stepping the tasks in between, and the keys arrive whenever they do
+				for _, key := range keys {
+					time.Sleep(time.Millisecond)
+					fmt.Fprintln(pw, key)
+				}
+				pw.Close()
+			}()
+
+			// without ghosts nothing else can end the game, so it runs until the input task reads "q"
+			world := newWorldTask(nil, 1)
+			state := world.state
+			input := &InputTask{src: NewChannelSource(cmds), state: state}
+			render := &RenderTask{state: state, screen: &frameRecorder{}}
+			clock := newVirtualClock(replayStart)
+			playMT(context.Background(), sched, clock, state, []StepTask{input, world, render}, newTraceRecorder(replayStart))
+
+			for range cmds { // readInputLines ends at the writer's Close
+			}
+			if state.GameOverMsg != "quit" {
+				t.Errorf("game over: %q, want quit", state.GameOverMsg)
+			}
+			// every key was applied, in order: five right, three down, two back left, the unknown one ignored
+			if want := (point{3, 3}); state.PacmanPos != want {
+				t.Errorf("Pac-Man at %v, want %v", state.PacmanPos, want)
+			}
+		})
+	}
+}
+
+// TestCancelEndsTheGame is Ctrl-C in mt: the loop ends the game itself, so the statistics and the trace follow.
+func TestCancelEndsTheGame(t *testing.T) {
+	sched, err := newScheduler("rr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	world := newWorldTask(nil, 1)
+	state := world.state
+	input := &InputTask{src: NewChannelSource(make(chan string)), state: state} // never a key
+	render := &RenderTask{state: state, screen: &frameRecorder{}}
+	clock := newVirtualClock(replayStart)
+	_, end := playMT(ctx, sched, clock, state, []StepTask{input, world, render}, newTraceRecorder(replayStart))
+
+	if state.GameOverMsg != "interrupted" {
+		t.Errorf("game over: %q, want interrupted", state.GameOverMsg)
+	}
+	if want := replayStart.Add(timeSlice); !end.Equal(want) {
+		t.Errorf("ended at %s, want the first tick %s", end, want)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"time"
 )
 
@@ -9,38 +11,68 @@ import (
 // The first thread (input) blocks forever, starving the others.
 
 type thread struct {
-	name string
-	task StepTask
+	name  string
+	task  StepTask
+	trace *traceRecorder
+	state *GameState // only touched while holding the CPU
 }
 
-func (th thread) run(cpu <-chan struct{}) {
+func (th thread) run(cpu <-chan struct{}, finished chan<- struct{}) {
 	<-cpu // acquire the only cpu core
 	fmt.Printf("[%s] acquired CPU (single-core)\n", th.name)
 
 	// in this no-multitasking model, all threads run indefinitely
 	// there is no scheduler to preempt it and no time slicing
 	for {
-		th.task.Step(time.Now(), true) // blocking
-		if isGameOver {
+		// a task that is not ready will block in Step, holding the only CPU while it waits
+		r, ok := th.task.(readier)
+		blocked := ok && !r.Ready()
+		start := time.Now()
+		th.task.Step(start, true) // blocking
+		th.trace.record(th.name, start, time.Now(), blocked)
+		if th.state.IsGameOver {
+			finished <- struct{}{}
 			return
 		}
 	}
 }
 
-func runNoMT() {
+// runNoMT reads whole lines from stdin, or the decoded key presses from keys if it is not nil.
+// Cancelling ctx stops waiting for the game to end; the input thread may still be stuck in its read.
+func runNoMT(ctx context.Context, tracePath string, keys <-chan string, seed int64, brains []GhostBrain) {
 	// unbuffered channel to model single-core cpu
 	cpu := make(chan struct{})
 
-	input := &InputTask{src: NewBlockingStdinSource()}
-	world := &WorldTask{}
-	render := &RenderTask{}
+	var src InputSource = NewBlockingStdinSource()
+	if keys != nil {
+		src = NewChannelSource(keys) // Get(block=true) waits on the channel, just as blocking
+	}
+	world := newWorldTask(brains, seed)
+	state := world.state
+	input := &InputTask{src: src, state: state}
+	render := &RenderTask{state: state, screen: terminalScreen{os.Stdout}}
+
+	start := time.Now()
+	trace := newTraceRecorder(start)
+	for _, t := range []StepTask{input, world, render} {
+		trace.addTask(t.Name())
+	}
+	finished := make(chan struct{}, 3)
 
 	// start all tasks, but ensure input gets cpu first (book's intent)
-	go thread{name: input.Name(), task: input}.run(cpu)
+	go thread{name: input.Name(), task: input, trace: trace, state: state}.run(cpu, finished)
 	time.Sleep(100 * time.Millisecond) // ensure input starts first
 	cpu <- struct{}{}
-	go thread{name: world.Name(), task: world}.run(cpu)
-	go thread{name: render.Name(), task: render}.run(cpu)
+	go thread{name: world.Name(), task: world, trace: trace, state: state}.run(cpu, finished)
+	go thread{name: render.Name(), task: render, trace: trace, state: state}.run(cpu, finished)
 
-	select {} // keep main alive forever
+	// the game can only end through input, the one thread that ever runs;
+	// the others are still waiting for the CPU when we show the trace
+	select {
+	case <-finished:
+		fmt.Println("Game over, the CPU was never released.")
+	case <-ctx.Done():
+		fmt.Println("Interrupted, the CPU was never released.")
+	}
+	exportTrace(trace, tracePath, time.Now())
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is where the scheduler gets its time from: the wall clock when playing,
+// or a virtual clock when replaying a script, so a replay gives the same result every time.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the scheduler's time slices.
+type Ticker interface {
+	// Wait blocks until the next tick and returns its time.
+	Wait() time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) Wait() time.Time { return <-t.t.C }
+func (t realTicker) Stop()           { t.t.Stop() }
+
+// virtualClock only moves when one of its tickers is waited on, and then jumps straight to the next tick:
+// a replay takes as long as its steps do, not as long as the game it replays, and every step
+// sees the same time on every run.
+type virtualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newVirtualClock(start time.Time) *virtualClock {
+	return &virtualClock{now: start}
+}
+
+func (c *virtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *virtualClock) NewTicker(d time.Duration) Ticker {
+	return &virtualTicker{c: c, d: d, next: c.Now().Add(d)}
+}
+
+type virtualTicker struct {
+	c    *virtualClock
+	d    time.Duration
+	next time.Time
+}
+
+func (t *virtualTicker) Wait() time.Time {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	t.c.now = t.next
+	t.next = t.next.Add(t.d)
+	return t.c.now
+}
+
+func (t *virtualTicker) Stop() {}
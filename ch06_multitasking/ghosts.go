@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// GhostBrain decides where one ghost moves next. The brains are what makes the world task
+// expensive: a chasing ghost searches the board every step, where a random one just rolls a die.
+type GhostBrain interface {
+	Name() string
+	// Next returns the ghost's next position, one step from its current one (or the same position).
+	Next(ghost int, s *GameState, rng *rand.Rand) point
+}
+
+// ghostStarts are the starting positions, one per ghost.
+var ghostStarts = []point{{5, 5}, {10, 10}, {15, 5}, {5, 15}}
+
+// randomBrain steps in a random direction, diagonals and standing still included.
+type randomBrain struct{}
+
+func (randomBrain) Name() string { return "random" }
+
+func (randomBrain) Next(ghost int, s *GameState, rng *rand.Rand) point {
+	g := s.Ghosts[ghost]
+	np := point{g.x + []int{-1, 0, 1}[rng.Intn(3)], g.y + []int{-1, 0, 1}[rng.Intn(3)]}
+	if !inBounds(np) {
+		return g
+	}
+	return np
+}
+
+// chaseBrain takes the shortest path to Pac-Man, going around the other ghosts.
+type chaseBrain struct{}
+
+func (chaseBrain) Name() string { return "chase" }
+
+func (chaseBrain) Next(ghost int, s *GameState, rng *rand.Rand) point {
+	return stepTowards(s, ghost, s.PacmanPos)
+}
+
+// ambushBrain heads for the spot ambushAhead cells in front of Pac-Man, to cut him off
+// instead of following him. Once there, or before Pac-Man's first move, it chases him directly.
+type ambushBrain struct{}
+
+const ambushAhead = 4
+
+func (ambushBrain) Name() string { return "ambush" }
+
+func (ambushBrain) Next(ghost int, s *GameState, rng *rand.Rand) point {
+	target := point{s.PacmanPos.x + ambushAhead*s.PacmanDir.x, s.PacmanPos.y + ambushAhead*s.PacmanDir.y}
+	clampToBounds(&target)
+	if target == s.Ghosts[ghost] {
+		target = s.PacmanPos
+	}
+	return stepTowards(s, ghost, target)
+}
+
+// scatterBrain ignores Pac-Man and patrols the corners of the board, clockwise.
+type scatterBrain struct {
+	corner int // the corner it is heading for
+}
+
+var corners = []point{{0, 0}, {GameWidth - 1, 0}, {GameWidth - 1, GameHeight - 1}, {0, GameHeight - 1}}
+
+func (b *scatterBrain) Name() string { return "scatter" }
+
+func (b *scatterBrain) Next(ghost int, s *GameState, rng *rand.Rand) point {
+	if s.Ghosts[ghost] == corners[b.corner] {
+		b.corner = (b.corner + 1) % len(corners)
+	}
+	return stepTowards(s, ghost, corners[b.corner])
+}
+
+// stepTowards returns the first step of a shortest path from the ghost to target, found by
+// breadth-first search over the board with the other ghosts as obstacles, though one sitting on
+// target does not make it unreachable. If target cannot be reached, the ghost stays where it is.
+func stepTowards(s *GameState, ghost int, target point) point {
+	from := s.Ghosts[ghost]
+	if from == target {
+		return from
+	}
+	index := func(p point) int { return p.y*GameWidth + p.x }
+
+	var prev [GameWidth * GameHeight]int // index+1 of the cell we came from; 0: not visited
+	blocked := func(p point) bool {
+		for i, g := range s.Ghosts {
+			if i != ghost && g == p {
+				return true
+			}
+		}
+		return false
+	}
+
+	prev[index(from)] = index(from) + 1
+	queue := []point{from}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p == target {
+			// walk back to the cell right after from
+			for prev[index(p)]-1 != index(from) {
+				i := prev[index(p)] - 1
+				p = point{i % GameWidth, i / GameWidth}
+			}
+			return p
+		}
+		for _, d := range []point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+			np := point{p.x + d.x, p.y + d.y}
+			if !inBounds(np) || prev[index(np)] != 0 || np != target && blocked(np) {
+				continue
+			}
+			prev[index(np)] = index(p) + 1
+			queue = append(queue, np)
+		}
+	}
+	return from
+}
+
+// newGhostBrains parses a comma-separated list of strategies, one ghost each:
+// random, chase, ambush or scatter.
+func newGhostBrains(list string) ([]GhostBrain, error) {
+	names := strings.Split(list, ",")
+	if len(names) > len(ghostStarts) {
+		return nil, fmt.Errorf("at most %d ghosts, got %d", len(ghostStarts), len(names))
+	}
+	var brains []GhostBrain
+	for i, name := range names {
+		switch strings.TrimSpace(name) {
+		case "random":
+			brains = append(brains, randomBrain{})
+		case "chase":
+			brains = append(brains, chaseBrain{})
+		case "ambush":
+			brains = append(brains, ambushBrain{})
+		case "scatter":
+			brains = append(brains, &scatterBrain{corner: i % len(corners)})
+		default:
+			return nil, fmt.Errorf("unknown ghost strategy %q, use random, chase, ambush or scatter", name)
+		}
+	}
+	return brains, nil
+}
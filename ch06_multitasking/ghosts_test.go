@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStepTowards(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		ghosts []point // ghosts[0] is the one moving
+		target point
+		want   []point // any of these first steps is a shortest path
+	}{
+		{"straight down", []point{{5, 5}}, point{5, 9}, []point{{5, 6}}},
+		{"straight left", []point{{5, 5}}, point{2, 5}, []point{{4, 5}}},
+		{"one step away", []point{{5, 5}}, point{6, 5}, []point{{6, 5}}},
+		{"already there", []point{{5, 5}}, point{5, 5}, []point{{5, 5}}},
+		{"around a ghost in the way", []point{{5, 5}, {5, 6}}, point{5, 7}, []point{{4, 5}, {6, 5}}},
+		{"along a wall of ghosts", []point{{5, 5}, {4, 6}, {5, 6}, {6, 6}}, point{5, 7}, []point{{4, 5}, {6, 5}}},
+		{"ghost on the target", []point{{5, 5}, {5, 9}}, point{5, 9}, []point{{5, 6}}},
+		{"ghost on the target next to it", []point{{5, 5}, {5, 6}}, point{5, 6}, []point{{5, 6}}},
+		{"boxed in by ghosts", []point{{0, 0}, {1, 0}, {0, 1}}, point{5, 5}, []point{{0, 0}}},
+		{"target walled off", []point{{5, 5}, {18, 19}, {19, 18}}, point{19, 19}, []point{{5, 5}}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := &GameState{Ghosts: c.ghosts}
+			if got := stepTowards(s, 0, c.target); !slices.Contains(c.want, got) {
+				t.Errorf("stepTowards(%v -> %v) = %v, want one of %v", c.ghosts[0], c.target, got, c.want)
+			}
+		})
+	}
+}
+
+// A scatter ghost that reaches its corner heads for the next one clockwise, and round again.
+func TestScatterCornerRotation(t *testing.T) {
+	for i, c := range []struct {
+		step       point
+		nextCorner int
+	}{
+		{point{1, 0}, 1},                          // from top left, along the top
+		{point{GameWidth - 1, 1}, 2},              // from top right, down the right side
+		{point{GameWidth - 2, GameHeight - 1}, 3}, // from bottom right, along the bottom
+		{point{0, GameHeight - 2}, 0},             // from bottom left, back up to the start
+	} {
+		b := &scatterBrain{corner: i}
+		s := &GameState{Ghosts: []point{corners[i]}}
+		if got := b.Next(0, s, nil); got != c.step {
+			t.Errorf("at corner %v stepped to %v, want %v", corners[i], got, c.step)
+		}
+		if b.corner != c.nextCorner {
+			t.Errorf("at corner %v heading for corner %d, want %d", corners[i], b.corner, c.nextCorner)
+		}
+	}
+
+	// on the way it keeps its corner
+	b := &scatterBrain{corner: 2}
+	s := &GameState{Ghosts: []point{{GameWidth - 1, 5}}}
+	if got, want := b.Next(0, s, nil), (point{GameWidth - 1, 6}); got != want || b.corner != 2 {
+		t.Errorf("on the way stepped to %v heading for corner %d, want %v and 2", got, b.corner, want)
+	}
+}
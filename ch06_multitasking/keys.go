@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// arrowKeys maps the final byte of an arrow-key escape sequence to the command for that direction.
+var arrowKeys = map[byte]string{'A': "w", 'B': "s", 'C': "d", 'D': "a"}
+
+// readKeys decodes key presses from r and sends them into cmds, and closes it when r ends or fails.
+// Arrow keys become w/a/s/d, Enter becomes an empty command and a lone Esc quits;
+// every other key is sent as is, so InputTask sees the same commands as with line input.
+func readKeys(r io.Reader, cmds chan<- string) {
+	defer close(cmds)
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case '\x1b':
+			if cmd, ok := readEscape(br); ok {
+				cmds <- cmd
+			}
+		case '\r', '\n':
+			cmds <- ""
+		default:
+			cmds <- string(rune(b))
+		}
+	}
+}
+
+// readEscape decodes what follows an Esc byte. The terminal writes a whole escape sequence at once,
+// so an Esc with nothing buffered behind it was the Esc key itself.
+// An arrow key is ESC [ A (or ESC O A in application mode), possibly with parameters for a modifier
+// (ESC [ 1 ; 5 A is Ctrl+Up). Other sequences, like F-keys, are consumed up to their final byte and ignored.
+func readEscape(br *bufio.Reader) (string, bool) {
+	if br.Buffered() == 0 {
+		return "q", true
+	}
+	intro, err := br.ReadByte()
+	if err != nil || intro != '[' && intro != 'O' {
+		return "", false // Alt+key, ignore
+	}
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return "", false
+		}
+		if b >= 0x40 && b <= 0x7e { // final byte
+			cmd, ok := arrowKeys[b]
+			return cmd, ok
+		}
+	}
+}
+
+// startRawKeys switches stdin to raw mode and starts decoding key presses into the returned channel.
+// The returned function restores the terminal; call it before exiting.
+func startRawKeys() (<-chan string, func(), error) {
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("raw input: %w", err)
+	}
+	keys := make(chan string, 16)
+	go readKeys(os.Stdin, keys)
+	return keys, restore, nil
+}
@@ -1,25 +1,92 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run . [nomt|mt]")
+		fmt.Println("Usage: go run . [nomt|mt|replay] [-sched=rr|priority|edf|mlfq] [-trace=file.json] [-raw] [-seed=n] [-ghosts=chase,ambush,...]")
+		fmt.Println("       go run . replay -script=keys.txt [-golden=frames.txt [-update]]")
 		os.Exit(1)
 	}
 
 	mode := os.Args[1]
 
+	// flags follow the mode, e.g. `go run . mt -sched=edf`
+	fs := flag.NewFlagSet(mode, flag.ExitOnError)
+	schedName := fs.String("sched", "rr", "mt and replay modes: scheduling policy, one of rr, priority, edf, mlfq")
+	tracePath := fs.String("trace", "", "write a Chrome trace-event JSON file when the game ends")
+	raw := fs.Bool("raw", false, "read single key presses (w/a/s/d or arrows, q or Esc) without Enter; Linux terminals only")
+	ghostList := fs.String("ghosts", "random,random", "one strategy per ghost, comma-separated: random, chase, ambush, scatter (at most 4)")
+	seed := fs.Int64("seed", 0, "seed for the ghosts' moves; 0 picks one from the clock, except in replay mode")
+	scriptPath := fs.String("script", "", "replay mode: keystroke script to play, lines of <time since start> <key>")
+	goldenPath := fs.String("golden", "", "replay mode: compare the rendered frames with this file")
+	update := fs.Bool("update", false, "replay mode: write the rendered frames to the -golden file instead of comparing")
+	_ = fs.Parse(os.Args[2:])
+
+	var sched Scheduler
 	switch mode {
 	case "nomt":
-		runNoMT()
-	case "mt":
-		runMT()
+	case "mt", "replay":
+		var err error
+		if sched, err = newScheduler(*schedName); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	default:
-		fmt.Printf("Unknown mode %q. Use 'nomt' or 'mt'.\n", mode)
+		fmt.Printf("Unknown mode %q. Use 'nomt', 'mt' or 'replay'.\n", mode)
+		os.Exit(1)
+	}
+
+	brains, err := newGhostBrains(*ghostList)
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
+
+	if mode == "replay" {
+		// a replay is reproducible, so the seed is whatever was given, 0 included
+		if *scriptPath == "" {
+			fmt.Println("replay mode needs a -script")
+			os.Exit(1)
+		}
+		if err := runReplay(sched, *tracePath, *seed, brains, *scriptPath, *goldenPath, *update); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	// from here on, exit by returning, so the terminal gets restored and the trace written.
+	// Ctrl-C, or being killed, ends the game rather than the program; a second one kills it at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	var keys <-chan string // nil: line-buffered stdin
+	if *raw {
+		k, restore, err := startRawKeys()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer restore()
+		keys = k
+	}
+
+	if mode == "nomt" {
+		runNoMT(ctx, *tracePath, keys, *seed, brains)
+	} else {
+		runMT(ctx, sched, *tracePath, keys, *seed, brains)
+	}
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"slices"
@@ -20,23 +21,41 @@ const (
 
 type point struct{ x, y int }
 
-// define the initial state of the game world
-var (
-	pacmanPos   = point{0, 0}
-	ghosts      = []point{{5, 5}, {10, 10}}
-	score       = -10
-	isGameOver  = false
-	gameOverMsg = ""
-	dots        = make(map[point]struct{}, GameWidth*GameHeight)
-)
+// GameState is the game world. It belongs to whoever runs the tasks: the scheduler loop in mt,
+// the thread holding the CPU in nomt. Only that goroutine may touch it; other goroutines,
+// like the stdin reader, talk to the tasks through channels instead of writing to the state.
+type GameState struct {
+	PacmanPos   point
+	PacmanDir   point // the last move, for ghosts that plan ahead
+	Ghosts      []point
+	Dots        map[point]struct{}
+	Score       int
+	IsGameOver  bool
+	GameOverMsg string
+}
 
-func init() {
+// NewGameState defines the initial state of the game world, with the given number of ghosts.
+func NewGameState(ghosts int) *GameState {
+	s := &GameState{
+		PacmanPos: point{0, 0},
+		Ghosts:    slices.Clone(ghostStarts[:ghosts]),
+		Score:     -10,
+		Dots:      make(map[point]struct{}, GameWidth*GameHeight),
+	}
 	// initialize dots in all positions
 	for x := range GameWidth {
 		for y := range GameHeight {
-			dots[point{x, y}] = struct{}{}
+			s.Dots[point{x, y}] = struct{}{}
 		}
 	}
+	return s
+}
+
+// end marks the game as over, keeping the first reason.
+func (s *GameState) end(msg string) {
+	if !s.IsGameOver {
+		s.IsGameOver, s.GameOverMsg = true, msg
+	}
 }
 
 // --------------------
@@ -64,14 +83,9 @@ func clampToBounds(p *point) {
 	}
 }
 
-// clearScreen clears the terminal screen.
-func clearScreen() {
-	fmt.Print("\033[2J\033[H") // ANSI clear + cursor home
-}
-
-// isGhosts checks if a point is occupied by a ghost.
-func isGhosts(p point) bool {
-	return slices.Contains(ghosts, p)
+// isGhost checks if a point is occupied by a ghost.
+func (s *GameState) isGhost(p point) bool {
+	return slices.Contains(s.Ghosts, p)
 }
 
 // --------------------
@@ -96,6 +110,12 @@ func NewBlockingStdinSource() *BlockingStdinSource {
 	return &BlockingStdinSource{r: bufio.NewReader(os.Stdin)}
 }
 
+// Pending reports whether a line has already been read from stdin into the buffer;
+// if not, Get will block.
+func (s *BlockingStdinSource) Pending() bool {
+	return s.r.Buffered() > 0
+}
+
 func (s *BlockingStdinSource) Get(block bool) (string, bool) {
 	// for this source, block is ignored; it's always blocking
 	line, err := s.r.ReadString('\n')
@@ -105,26 +125,58 @@ func (s *BlockingStdinSource) Get(block bool) (string, bool) {
 	return strings.TrimSpace(line), true
 }
 
-// SharedBufferSource reads a shared string buffer. It represents non-blocking input polling.
+// ChannelSource receives commands from a channel. It represents non-blocking input polling.
 //
-// A separate goroutine is expected to fill the buffer asynchronously.
-// The multitasking version implements that goroutine function with `readInputBlockingInto`.
-type SharedBufferSource struct {
-	buf *string
+// A separate goroutine is expected to send into the channel asynchronously, and to close it
+// when the input ends. The multitasking version implements that goroutine function with `readInputLines`.
+// The channel is the only thing the two goroutines share, so no command is lost or half-written.
+type ChannelSource struct {
+	cmds   <-chan string
+	next   string // a command received by Pending, not yet handed out by Get
+	peeked bool
+	closed bool
 }
 
-func NewSharedBufferSource(buf *string) *SharedBufferSource {
-	return &SharedBufferSource{buf: buf}
+func NewChannelSource(cmds <-chan string) *ChannelSource {
+	return &ChannelSource{cmds: cmds}
 }
 
-func (s *SharedBufferSource) Get(block bool) (string, bool) {
-	// non-blocking by design
-	if s.buf == nil || *s.buf == "" {
+// Pending reports whether a command is waiting (or the input has ended), without consuming it.
+// A channel cannot be peeked at, so a waiting command is received and kept for the next Get.
+func (s *ChannelSource) Pending() bool {
+	if s.peeked || s.closed {
+		return true
+	}
+	select {
+	case cmd, ok := <-s.cmds:
+		s.next, s.peeked, s.closed = cmd, ok, !ok
+	default:
+	}
+	return s.peeked || s.closed
+}
+
+// Err reports whether the input has ended; no command will come any more.
+func (s *ChannelSource) Err() error {
+	if s.closed {
+		return io.EOF
+	}
+	return nil
+}
+
+func (s *ChannelSource) Get(block bool) (string, bool) {
+	if !block && !s.Pending() {
+		return "", false // nothing waiting, never block the scheduler
+	}
+	if s.peeked {
+		s.peeked = false
+		return s.next, true
+	}
+	if s.closed {
 		return "", false
 	}
-	cmd := *s.buf
-	*s.buf = "" // consume and reset
-	return cmd, true
+	cmd, ok := <-s.cmds
+	s.closed = !ok
+	return cmd, ok
 }
 
 // --------------------
@@ -138,112 +190,156 @@ type StepTask interface {
 }
 
 type InputTask struct {
-	src InputSource
+	src   InputSource
+	state *GameState
 }
 
 func (t *InputTask) Name() string          { return "getUserInput" }
 func (t *InputTask) Period() time.Duration { return 0 } // can run every slice
 func (t *InputTask) Step(now time.Time, block bool) {
-	if isGameOver {
+	s := t.state
+	if s.IsGameOver {
 		return
 	}
 
 	cmd, ok := t.src.Get(block)
 	if !ok {
-		// in mt mode (block=false), "no input" is normal
+		// in mt mode (block=false), "no input" is normal, unless the source has ended
 		// in no-mt mode (block=true), ok=false can mean stdin closed/error
-		if block {
-			isGameOver = true
-			gameOverMsg = "input error"
+		if e, canFail := t.src.(interface{ Err() error }); block || canFail && e.Err() != nil {
+			s.end("input error")
 		}
 		return
 	}
 
 	switch cmd {
 	case "q":
-		isGameOver = true
-		gameOverMsg = "quit"
+		s.end("quit")
 		return
 	case "w":
-		pacmanPos = point{pacmanPos.x, pacmanPos.y - 1}
+		s.PacmanDir = point{0, -1}
 	case "a":
-		pacmanPos = point{pacmanPos.x - 1, pacmanPos.y}
+		s.PacmanDir = point{-1, 0}
 	case "s":
-		pacmanPos = point{pacmanPos.x, pacmanPos.y + 1}
+		s.PacmanDir = point{0, 1}
 	case "d":
-		pacmanPos = point{pacmanPos.x + 1, pacmanPos.y}
+		s.PacmanDir = point{1, 0}
 	default:
-		// ignore
+		return // ignore
+	}
+	s.PacmanPos = point{s.PacmanPos.x + s.PacmanDir.x, s.PacmanPos.y + s.PacmanDir.y}
+	clampToBounds(&s.PacmanPos)
+}
+
+// Ready reports whether there is input to process; without any, the task is blocked on I/O.
+// Sources that cannot tell are always ready.
+func (t *InputTask) Ready() bool {
+	if p, ok := t.src.(interface{ Pending() bool }); ok {
+		return t.state.IsGameOver || p.Pending()
 	}
-	clampToBounds(&pacmanPos)
+	return true
+}
+
+type WorldTask struct {
+	state  *GameState
+	rng    *rand.Rand   // seeded, so the ghosts can move the same way again
+	brains []GhostBrain // one per ghost
 }
 
-type WorldTask struct{}
+// newWorldTask starts a game with one ghost per brain.
+func newWorldTask(brains []GhostBrain, seed int64) *WorldTask {
+	return &WorldTask{state: NewGameState(len(brains)), rng: rand.New(rand.NewSource(seed)), brains: brains}
+}
 
 func (t *WorldTask) Name() string          { return "computeGameWorld" }
 func (t *WorldTask) Period() time.Duration { return Delay }
 func (t *WorldTask) Step(now time.Time, block bool) {
-	if isGameOver {
+	s := t.state
+	if s.IsGameOver {
 		return
 	}
 
-	// move ghosts randomly
-	for i, g := range ghosts {
-		nx := g.x + []int{-1, 0, 1}[rand.Intn(3)]
-		ny := g.y + []int{-1, 0, 1}[rand.Intn(3)]
-		np := point{nx, ny}
-		if inBounds(np) {
-			ghosts[i] = np
-		}
+	// move ghosts, each as its brain decides
+	for i, brain := range t.brains {
+		s.Ghosts[i] = brain.Next(i, s, t.rng)
 	}
 
 	// check collision pacman with ghost
-	if slices.Contains(ghosts, pacmanPos) {
-		isGameOver = true
-		gameOverMsg = "caught"
+	if s.isGhost(s.PacmanPos) {
+		s.end("caught")
 		return
 	}
 
 	// pacman eat dot
-	if _, ok := dots[pacmanPos]; ok {
-		delete(dots, pacmanPos)
-		score += 10
+	if _, ok := s.Dots[s.PacmanPos]; ok {
+		delete(s.Dots, s.PacmanPos)
+		s.Score += 10
 	}
 
 	// win condition
-	if len(dots) == 0 {
-		isGameOver = true
-		gameOverMsg = "win"
+	if len(s.Dots) == 0 {
+		s.end("win")
 		return
 	}
 }
 
-type RenderTask struct{}
+// --------------------
+// Render targets
+// --------------------
+
+// RenderTarget is where RenderTask draws its frames.
+type RenderTarget interface {
+	Draw(frame string)
+}
+
+// terminalScreen draws every frame over the previous one.
+type terminalScreen struct {
+	w io.Writer
+}
+
+func (s terminalScreen) Draw(frame string) {
+	fmt.Fprint(s.w, "\033[2J\033[H"+frame) // ANSI clear + cursor home
+}
+
+// frameRecorder keeps the frames instead of showing them, to compare a replay against a golden file.
+type frameRecorder struct {
+	frames []string
+}
+
+func (r *frameRecorder) Draw(frame string) {
+	r.frames = append(r.frames, frame)
+}
+
+type RenderTask struct {
+	state  *GameState
+	screen RenderTarget
+}
 
 func (t *RenderTask) Name() string          { return "renderNextScreen" }
 func (t *RenderTask) Period() time.Duration { return Delay }
 func (t *RenderTask) Step(now time.Time, block bool) {
-	clearScreen()
-
-	if isGameOver {
-		fmt.Println("GAME OVER!")
-		fmt.Printf("Your score: %d. (reason: %s)\n", score, gameOverMsg)
-		fmt.Println("Press Enter to exit.")
+	s := t.state
+	var b strings.Builder
+
+	if s.IsGameOver {
+		fmt.Fprintln(&b, "GAME OVER!")
+		fmt.Fprintf(&b, "Your score: %d. (reason: %s)\n", s.Score, s.GameOverMsg)
+		fmt.Fprintln(&b, "Press Enter to exit.")
+		t.screen.Draw(b.String())
 		return
 	}
 
-	fmt.Printf("Score: %d. Press 'q' then Enter to quit.\n", score)
+	fmt.Fprintf(&b, "Score: %d. Press 'q' then Enter to quit.\n", s.Score)
 
 	for y := range GameHeight {
-		var b strings.Builder
 		for x := range GameWidth {
 			p := point{x, y}
 			char := " "
-			if p == pacmanPos {
+			if p == s.PacmanPos {
 				char = "P"
-			} else if isGhosts(p) {
+			} else if s.isGhost(p) {
 				char = "G"
-			} else if _, ok := dots[p]; ok {
+			} else if _, ok := s.Dots[p]; ok {
 				char = "."
 			}
 			if x > 0 {
@@ -251,6 +347,7 @@ func (t *RenderTask) Step(now time.Time, block bool) {
 			}
 			b.WriteString(char)
 		}
-		fmt.Println(b.String())
+		b.WriteByte('\n')
 	}
+	t.screen.Draw(b.String())
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// scriptedKey is one line of a keystroke script: a command, due some time after the start of the game.
+type scriptedKey struct {
+	at  time.Duration
+	cmd string
+}
+
+// parseScript reads a keystroke script, one key per line: the time since the start, then the command.
+//
+//	# comments and blank lines are skipped
+//	500ms d
+//	1.5s  s
+//	4s    q
+func parseScript(r io.Reader) ([]scriptedKey, error) {
+	var keys []scriptedKey
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want a time and a key, got %q", n, line)
+		}
+		at, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if len(keys) > 0 && at < keys[len(keys)-1].at {
+			return nil, fmt.Errorf("line %d: %s is earlier than the key before it", n, at)
+		}
+		keys = append(keys, scriptedKey{at: at, cmd: fields[1]})
+	}
+	return keys, sc.Err()
+}
+
+// ScriptSource replays a keystroke script against a clock: a key becomes available once its time has come.
+// After the last key the input has ended, like stdin at EOF.
+type ScriptSource struct {
+	keys  []scriptedKey
+	clock Clock
+	start time.Time
+}
+
+func NewScriptSource(keys []scriptedKey, clock Clock) *ScriptSource {
+	return &ScriptSource{keys: keys, clock: clock, start: clock.Now()}
+}
+
+// Pending reports whether a key is due (or the script is over).
+func (s *ScriptSource) Pending() bool {
+	return len(s.keys) == 0 || s.clock.Now().Sub(s.start) >= s.keys[0].at
+}
+
+// Err reports whether the script is over.
+func (s *ScriptSource) Err() error {
+	if len(s.keys) == 0 {
+		return io.EOF
+	}
+	return nil
+}
+
+func (s *ScriptSource) Get(block bool) (string, bool) {
+	// a script never blocks: waiting would only stop the clock that brings the next key
+	if len(s.keys) == 0 || !s.Pending() {
+		return "", false
+	}
+	cmd := s.keys[0].cmd
+	s.keys = s.keys[1:]
+	return cmd, true
+}
+
+// replayStart is when every replay begins, so that the traces of two replays are identical too.
+var replayStart = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// runReplay plays the multitasking version headless: keys from a script, time from a virtual clock,
+// ghosts from a seeded generator, and the frames recorded instead of drawn. With the same script, seed
+// and scheduler, every replay renders the same frames, which is what the golden file checks.
+func runReplay(sched Scheduler, tracePath string, seed int64, brains []GhostBrain, scriptPath, goldenPath string, update bool) error {
+	f, err := os.Open(scriptPath)
+	if err != nil {
+		return err
+	}
+	keys, err := parseScript(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", scriptPath, err)
+	}
+
+	r := replay(sched, seed, brains, keys)
+	fmt.Print(r.frames.frames[len(r.frames.frames)-1])
+	r.d.printStats(os.Stdout)
+	fmt.Printf("Seed: %d, %d frames in %s of game time\n", seed, len(r.frames.frames), r.end.Sub(replayStart))
+	exportTrace(r.trace, tracePath, r.end)
+
+	if goldenPath == "" {
+		return nil
+	}
+	got := r.frames.String()
+	if update {
+		if err := os.WriteFile(goldenPath, []byte(got), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Golden file %s updated.\n", goldenPath)
+		return nil
+	}
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		return err
+	}
+	if err := compareFrames(got, string(want)); err != nil {
+		return fmt.Errorf("%s: %w", goldenPath, err)
+	}
+	fmt.Printf("All frames match %s.\n", goldenPath)
+	return nil
+}
+
+// replayRun is what a replay leaves behind: the frames it rendered, the statistics and the trace.
+type replayRun struct {
+	frames *frameRecorder
+	d      *dispatcher
+	trace  *traceRecorder
+	end    time.Time
+}
+
+// replay plays keys to the end of the game, or of the script, without printing anything.
+func replay(sched Scheduler, seed int64, brains []GhostBrain, keys []scriptedKey) replayRun {
+	clock := newVirtualClock(replayStart)
+	frames := &frameRecorder{}
+	world := newWorldTask(brains, seed)
+	state := world.state
+	input := &InputTask{src: NewScriptSource(keys, clock), state: state}
+	render := &RenderTask{state: state, screen: frames}
+
+	trace := newTraceRecorder(replayStart)
+	d, end := playMT(context.Background(), sched, clock, state, []StepTask{input, world, render}, trace)
+	render.Step(end, false)
+	return replayRun{frames: frames, d: d, trace: trace, end: end}
+}
+
+const frameSeparator = "--- frame %d ---\n"
+
+// String joins the frames into the golden file format: each frame after a numbered separator line.
+func (r *frameRecorder) String() string {
+	var b strings.Builder
+	for i, frame := range r.frames {
+		fmt.Fprintf(&b, frameSeparator, i+1)
+		b.WriteString(frame)
+	}
+	return b.String()
+}
+
+// compareFrames reports the first frame where got differs from want, showing both.
+func compareFrames(got, want string) error {
+	if got == want {
+		return nil
+	}
+	split := func(s string) []string {
+		var frames []string
+		for i := 1; s != ""; i++ {
+			s = strings.TrimPrefix(s, fmt.Sprintf(frameSeparator, i))
+			next := strings.Index(s, fmt.Sprintf(frameSeparator, i+1))
+			if next < 0 {
+				next = len(s)
+			}
+			frames = append(frames, s[:next])
+			s = s[next:]
+		}
+		return frames
+	}
+	g, w := split(got), split(want)
+	for i := range min(len(g), len(w)) {
+		if g[i] != w[i] {
+			return fmt.Errorf("frame %d differs\n--- got:\n%s--- want:\n%s", i+1, g[i], w[i])
+		}
+	}
+	return fmt.Errorf("got %d frames, want %d", len(g), len(w))
+}
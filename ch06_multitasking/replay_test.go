@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestReplayMatchesGolden replays testdata/demo.keys the way demo.golden was recorded,
+// go run . replay -script=testdata/demo.keys -seed=1 -golden=testdata/demo.golden -update,
+// and checks that every scheduling policy renders the same frames: the script leaves each task
+// enough slices that the policy decides when a step runs within a period, never what it draws.
+func TestReplayMatchesGolden(t *testing.T) {
+	const seed = 1
+	f, err := os.Open("testdata/demo.keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := parseScript(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/demo.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"rr", "priority", "edf", "mlfq"} {
+		t.Run(name, func(t *testing.T) {
+			sched, err := newScheduler(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			brains, err := newGhostBrains("random,random") // the -ghosts default
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := replay(sched, seed, brains, keys)
+			if err := compareFrames(r.frames.String(), string(want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// --------------------
+// Scheduling policies
+// --------------------
+
+// Scheduler decides which ready task gets the next time slice.
+// The CPU runs one task per slice; a task that is not eligible yet (its period has not elapsed)
+// or blocked (it has nothing to do, like input with no key pressed) is not offered.
+type Scheduler interface {
+	Name() string
+	// Pick chooses among the ready tasks, which are in the order they became ready.
+	Pick(ready []*taskState) *taskState
+	// Ran reports that t has just used a slice and how long it ran, so a policy can adapt.
+	Ran(t *taskState, ran time.Duration, now time.Time)
+}
+
+// readier is implemented by tasks that know whether they have anything to do.
+// A task that is not ready is blocked and waits outside the ready queue.
+type readier interface {
+	Ready() bool
+}
+
+// taskState is the scheduler's bookkeeping for one task, like a process control block.
+type taskState struct {
+	task     StepTask
+	nextRun  time.Time // eligible from then on
+	queued   bool      // in the ready queue
+	seq      int       // order of entering the ready queue, for FIFO tie-breaking
+	readyAt  time.Time // when it entered the ready queue
+	deadline time.Time // earliest-deadline-first: when this run should be done
+	level    int       // multilevel feedback queue: 0 is the top queue
+	stats    taskStats
+
+	blockedSince time.Time // for the trace: when it was first seen eligible but blocked
+}
+
+// taskStats accumulates how long a task waited in the ready queue and how long it ran.
+type taskStats struct {
+	runs          int
+	wait, maxWait time.Duration
+	run, maxRun   time.Duration
+}
+
+// roundRobin gives the slice to whoever has waited longest: nobody starves, nobody is favoured.
+type roundRobin struct{}
+
+func (roundRobin) Name() string                             { return "rr" }
+func (roundRobin) Pick(ready []*taskState) *taskState       { return ready[0] }
+func (roundRobin) Ran(*taskState, time.Duration, time.Time) {}
+
+// priorityScheduler always runs the ready task with the highest static priority.
+// A high-priority task that is always ready starves everyone below it, which is why the input task,
+// at the top, must block while there is no input.
+type priorityScheduler struct {
+	priorities map[string]int // by task name, higher runs first; unknown tasks get 0
+}
+
+func (s priorityScheduler) Name() string { return "priority" }
+
+func (s priorityScheduler) Pick(ready []*taskState) *taskState {
+	best := ready[0]
+	for _, t := range ready[1:] {
+		if s.priorities[t.task.Name()] > s.priorities[best.task.Name()] {
+			best = t
+		}
+	}
+	return best
+}
+
+func (priorityScheduler) Ran(*taskState, time.Duration, time.Time) {}
+
+// edfScheduler runs the ready task whose deadline comes first. A periodic task must finish
+// before its next period starts; a task without a period within one slice of becoming ready.
+type edfScheduler struct{}
+
+func (edfScheduler) Name() string { return "edf" }
+
+func (edfScheduler) Pick(ready []*taskState) *taskState {
+	return slices.MinFunc(ready, func(a, b *taskState) int { return a.deadline.Compare(b.deadline) })
+}
+
+func (edfScheduler) Ran(*taskState, time.Duration, time.Time) {}
+
+// mlfqScheduler is a multilevel feedback queue: tasks start in the top queue, and a task
+// that runs longer than its queue's quantum is demoted, so short, interactive tasks stay ahead
+// of CPU-hungry ones without anyone declaring priorities. Every boostEvery all tasks move back up,
+// so a demoted task cannot starve and one that became interactive again is noticed.
+type mlfqScheduler struct {
+	quanta     []time.Duration // per level; the last level has no limit
+	boostEvery time.Duration
+	lastBoost  time.Time
+	all        []*taskState
+}
+
+func (s *mlfqScheduler) Name() string { return "mlfq" }
+
+func (s *mlfqScheduler) Pick(ready []*taskState) *taskState {
+	// lowest level first, FIFO (the ready order) within a level
+	best := ready[0]
+	for _, t := range ready[1:] {
+		if t.level < best.level {
+			best = t
+		}
+	}
+	return best
+}
+
+func (s *mlfqScheduler) Ran(t *taskState, ran time.Duration, now time.Time) {
+	if t.level < len(s.quanta)-1 && ran > s.quanta[t.level] {
+		t.level++
+	}
+	if s.lastBoost.IsZero() {
+		s.lastBoost = now
+	}
+	if now.Sub(s.lastBoost) >= s.boostEvery {
+		for _, t := range s.all {
+			t.level = 0
+		}
+		s.lastBoost = now
+	}
+}
+
+// newScheduler returns the policy called name, one of rr, priority, edf and mlfq.
+func newScheduler(name string) (Scheduler, error) {
+	switch name {
+	case "rr":
+		return roundRobin{}, nil
+	case "priority":
+		return priorityScheduler{priorities: map[string]int{
+			"getUserInput":     2, // respond to the player first
+			"computeGameWorld": 1,
+			"renderNextScreen": 0,
+		}}, nil
+	case "edf":
+		return edfScheduler{}, nil
+	case "mlfq":
+		return &mlfqScheduler{
+			quanta:     []time.Duration{100 * time.Microsecond, time.Millisecond, 0},
+			boostEvery: 5 * time.Second,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown scheduler %q, use rr, priority, edf or mlfq", name)
+}
+
+// --------------------
+// Dispatching
+// --------------------
+
+// dispatcher owns the task states and runs one task per time slice, as chosen by the policy.
+type dispatcher struct {
+	sched  Scheduler
+	states []*taskState
+	seq    int
+	idle   int // slices in which no task was ready
+	trace  *traceRecorder
+	clock  Clock // measures the steps; a virtual clock makes them all take no time
+}
+
+func newDispatcher(sched Scheduler, tasks []StepTask, clock Clock, trace *traceRecorder) *dispatcher {
+	d := &dispatcher{sched: sched, trace: trace, clock: clock}
+	now := clock.Now()
+	for _, t := range tasks {
+		d.states = append(d.states, &taskState{task: t, nextRun: now})
+		trace.addTask(t.Name())
+	}
+	if m, ok := sched.(*mlfqScheduler); ok {
+		m.all = d.states
+	}
+	return d
+}
+
+// ready moves newly eligible, unblocked tasks into the ready queue and returns the queue in FIFO order.
+func (d *dispatcher) ready(now time.Time) []*taskState {
+	var ready []*taskState
+	for _, t := range d.states {
+		if !t.queued {
+			if now.Before(t.nextRun) {
+				continue // not yet eligible
+			}
+			if r, ok := t.task.(readier); ok && !r.Ready() {
+				if t.blockedSince.IsZero() {
+					t.blockedSince = now
+				}
+				continue // blocked
+			}
+			d.endBlocked(t, now)
+			d.seq++
+			t.queued, t.seq, t.readyAt = true, d.seq, now
+			t.deadline = now.Add(max(t.task.Period(), timeSlice))
+		}
+		ready = append(ready, t)
+	}
+	slices.SortFunc(ready, func(a, b *taskState) int { return a.seq - b.seq })
+	return ready
+}
+
+// runSlice gives this slice to one ready task, if any.
+func (d *dispatcher) runSlice(now time.Time) {
+	ready := d.ready(now)
+	if len(ready) == 0 {
+		d.idle++
+		return
+	}
+	t := d.sched.Pick(ready)
+
+	start := d.clock.Now()
+	wait := start.Sub(t.readyAt)
+	// in multitasking mode, Step must never block
+	t.task.Step(now, false)
+	end := d.clock.Now()
+	ran := end.Sub(start)
+	d.trace.record(t.task.Name(), start, end, false)
+
+	t.stats.runs++
+	t.stats.wait += wait
+	t.stats.maxWait = max(t.stats.maxWait, wait)
+	t.stats.run += ran
+	t.stats.maxRun = max(t.stats.maxRun, ran)
+	d.sched.Ran(t, ran, now)
+
+	// back out of the ready queue until its next period; period=0: eligible again next slice
+	t.queued = false
+	t.nextRun = now.Add(t.task.Period())
+}
+
+func (d *dispatcher) endBlocked(t *taskState, now time.Time) {
+	if !t.blockedSince.IsZero() {
+		d.trace.record(t.task.Name(), t.blockedSince, now, true)
+		t.blockedSince = time.Time{}
+	}
+}
+
+// finish closes the spans still open at the end of the game.
+func (d *dispatcher) finish(now time.Time) {
+	for _, t := range d.states {
+		d.endBlocked(t, now)
+	}
+}
+
+// printStats writes per-task wait and run times.
+func (d *dispatcher) printStats(w io.Writer) {
+	fmt.Fprintf(w, "Scheduler: %s, time slice %s, %d idle slices\n", d.sched.Name(), timeSlice, d.idle)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "task\truns\tavg wait\tmax wait\tavg run\tmax run\t")
+	for _, t := range d.states {
+		s := t.stats
+		avg := func(total time.Duration) time.Duration {
+			if s.runs == 0 {
+				return 0
+			}
+			return total / time.Duration(s.runs)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t\n", t.task.Name(), s.runs,
+			avg(s.wait).Round(time.Microsecond), s.maxWait.Round(time.Microsecond),
+			avg(s.run).Round(time.Microsecond), s.maxRun.Round(time.Microsecond))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// busyTask is always ready and has something to do every slice.
+type busyTask string
+
+func (t busyTask) Name() string        { return string(t) }
+func (busyTask) Period() time.Duration { return 0 }
+func (busyTask) Step(time.Time, bool)  {}
+
+// states returns fresh bookkeeping for tasks with the given names, in ready order.
+func states(names ...string) []*taskState {
+	var ts []*taskState
+	for i, name := range names {
+		ts = append(ts, &taskState{task: busyTask(name), seq: i + 1})
+	}
+	return ts
+}
+
+func newTestMLFQ(t *testing.T, all []*taskState) *mlfqScheduler {
+	t.Helper()
+	sched, err := newScheduler("mlfq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := sched.(*mlfqScheduler)
+	m.all = all
+	return m
+}
+
+// A task that runs past its queue's quantum drops a level, down to the last one;
+// one that stays within it keeps its level and is picked first.
+func TestMLFQDemotion(t *testing.T) {
+	ts := states("hungry", "short")
+	hungry, short := ts[0], ts[1]
+	m := newTestMLFQ(t, ts)
+	tick := newVirtualClock(replayStart).NewTicker(timeSlice)
+
+	for _, c := range []struct {
+		t         *taskState
+		ran       time.Duration
+		wantLevel int
+	}{
+		{short, m.quanta[0], 0}, // a quantum used up exactly is not exceeded
+		{hungry, m.quanta[0] + 1, 1},
+		{hungry, m.quanta[1] + 1, 2},
+		{hungry, time.Second, 2}, // the last level has no limit
+	} {
+		m.Ran(c.t, c.ran, tick.Wait())
+		if c.t.level != c.wantLevel {
+			t.Errorf("%s at level %d after running %s, want %d", c.t.task.Name(), c.t.level, c.ran, c.wantLevel)
+		}
+	}
+	if got := m.Pick(ts); got != short {
+		t.Errorf("picked %s, want the task still in the top queue", got.task.Name())
+	}
+}
+
+// Every boostEvery all tasks go back to the top queue, so a demoted task gets its turn again.
+func TestMLFQBoost(t *testing.T) {
+	ts := states("hungry", "short")
+	hungry, short := ts[0], ts[1]
+	m := newTestMLFQ(t, ts)
+	tick := newVirtualClock(replayStart).NewTicker(m.boostEvery / 5)
+
+	m.Ran(hungry, time.Second, tick.Wait()) // demoted, and the boost period starts
+	for range 4 {
+		m.Ran(short, 0, tick.Wait())
+		if hungry.level == 0 {
+			t.Fatalf("boosted before %s had passed", m.boostEvery)
+		}
+	}
+	m.Ran(short, 0, tick.Wait())
+	if hungry.level != 0 {
+		t.Errorf("hungry at level %d %s after the first run, want 0", hungry.level, m.boostEvery)
+	}
+}
+
+func TestEDFPicksEarliestDeadline(t *testing.T) {
+	ts := states("render", "world", "input")
+	tick := newVirtualClock(replayStart).NewTicker(timeSlice)
+	for i := len(ts) - 1; i >= 0; i-- { // the last to become ready has the earliest deadline
+		ts[i].deadline = tick.Wait()
+	}
+	if got := (edfScheduler{}).Pick(ts); got != ts[2] {
+		t.Errorf("picked %s, want input, whose deadline comes first", got.task.Name())
+	}
+}
+
+// With static priorities a task that is always ready starves the ones below it,
+// while round robin shares the slices out.
+func TestPriorityStarvation(t *testing.T) {
+	for _, c := range []struct {
+		sched    string
+		wantRuns map[string]int
+	}{
+		{"priority", map[string]int{"getUserInput": 10, "renderNextScreen": 0}},
+		{"rr", map[string]int{"getUserInput": 5, "renderNextScreen": 5}},
+	} {
+		t.Run(c.sched, func(t *testing.T) {
+			sched, err := newScheduler(c.sched)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock := newVirtualClock(replayStart)
+			tasks := []StepTask{busyTask("renderNextScreen"), busyTask("getUserInput")}
+			d := newDispatcher(sched, tasks, clock, newTraceRecorder(replayStart))
+			tick := clock.NewTicker(timeSlice)
+			for range 10 {
+				d.runSlice(tick.Wait())
+			}
+			for _, s := range d.states {
+				if got, want := s.stats.runs, c.wantRuns[s.task.Name()]; got != want {
+					t.Errorf("%s ran %d times in 10 slices, want %d", s.task.Name(), got, want)
+				}
+			}
+		})
+	}
+}
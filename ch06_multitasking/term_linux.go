@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func ioctlTermios(fd int, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return os.NewSyscallError("ioctl", errno)
+	}
+	return nil
+}
+
+// makeRaw turns off line buffering and echo on the terminal fd, so every key press is readable at once.
+// Signal keys (Ctrl-C) and output processing stay on: Ctrl-C still interrupts and "\n" still starts a new line.
+//
+// The returned function puts the terminal back as it was; otherwise the shell would be left without echo.
+// main catches SIGINT, SIGTERM and SIGHUP and returns normally, so it gets to call it then too.
+func makeRaw(fd int) (restore func(), err error) {
+	var old syscall.Termios
+	if err := ioctlTermios(fd, syscall.TCGETS, &old); err != nil {
+		return nil, err // ENOTTY: stdin is not a terminal
+	}
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON // Enter arrives as '\r'; Ctrl-S/Ctrl-Q are plain keys
+	raw.Cc[syscall.VMIN] = 1                   // a read returns as soon as one byte is there
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctlTermios(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return func() { _ = ioctlTermios(fd, syscall.TCSETS, &old) }, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw keyboard input is only available on Linux (it relies on termios ioctls)")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceEvent is one span of a task's life: running a step, or blocked waiting for I/O.
+type traceEvent struct {
+	task       string
+	start, end time.Time
+	blocked    bool
+}
+
+// traceRecorder collects events from the scheduler loop (or the threads of the nomt version)
+// so the interleaving of the tasks can be looked at after the game, instead of guessed from the screen.
+type traceRecorder struct {
+	mu     sync.Mutex
+	origin time.Time
+	tasks  []string // in order of first appearance, one row each
+	events []traceEvent
+}
+
+func newTraceRecorder(now time.Time) *traceRecorder {
+	return &traceRecorder{origin: now}
+}
+
+func (r *traceRecorder) record(task string, start, end time.Time, blocked bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.tasks, task) {
+		r.tasks = append(r.tasks, task)
+	}
+	r.events = append(r.events, traceEvent{task: task, start: start, end: end, blocked: blocked})
+}
+
+// addTask gives task a row even if it never gets to run, like the starved threads of nomt.
+func (r *traceRecorder) addTask(task string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.tasks, task) {
+		r.tasks = append(r.tasks, task)
+	}
+}
+
+// writeChromeTrace writes the events in the Chrome trace-event format,
+// to be opened in chrome://tracing or https://ui.perfetto.dev. Every task is a thread.
+func (r *traceRecorder) writeChromeTrace(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type event struct {
+		Name string         `json:"name"`
+		Cat  string         `json:"cat,omitempty"`
+		Ph   string         `json:"ph"`
+		Ts   float64        `json:"ts"` // microseconds
+		Dur  float64        `json:"dur,omitempty"`
+		Pid  int            `json:"pid"`
+		Tid  int            `json:"tid"`
+		Args map[string]any `json:"args,omitempty"`
+	}
+	micros := func(d time.Duration) float64 { return float64(d) / float64(time.Microsecond) }
+
+	var events []event
+	for i, task := range r.tasks {
+		events = append(events, event{Name: "thread_name", Ph: "M", Pid: 1, Tid: i + 1, Args: map[string]any{"name": task}})
+	}
+	for _, e := range r.events {
+		name, cat := e.task, "run"
+		if e.blocked {
+			name, cat = e.task+" (blocked)", "blocked"
+		}
+		events = append(events, event{
+			Name: name, Cat: cat, Ph: "X",
+			Ts: micros(e.start.Sub(r.origin)), Dur: micros(e.end.Sub(e.start)),
+			Pid: 1, Tid: slices.Index(r.tasks, e.task) + 1,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(map[string]any{"traceEvents": events, "displayTimeUnit": "ms"})
+}
+
+// writeGantt draws one row per task over width columns of equal time:
+// '#' the task ran during that column, '.' it was blocked, ' ' neither.
+// Steps take microseconds, so a column shows '#' if the task ran at all within it.
+func (r *traceRecorder) writeGantt(w io.Writer, width int, end time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := end.Sub(r.origin)
+	if total <= 0 || len(r.tasks) == 0 {
+		return
+	}
+	col := func(t time.Time) int {
+		return min(width-1, max(0, int(int64(t.Sub(r.origin))*int64(width)/int64(total))))
+	}
+
+	rows := make(map[string][]byte, len(r.tasks))
+	nameWidth := 0
+	for _, task := range r.tasks {
+		rows[task] = []byte(strings.Repeat(" ", width))
+		nameWidth = max(nameWidth, len(task))
+	}
+	for _, blocked := range []bool{true, false} { // runs drawn last, over blocked spans
+		for _, e := range r.events {
+			if e.blocked != blocked {
+				continue
+			}
+			c := byte('#')
+			if blocked {
+				c = '.'
+			}
+			for i := col(e.start); i <= col(e.end); i++ {
+				rows[e.task][i] = c
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%*s |0%*s|\n", nameWidth, "", width-1, total.Round(time.Millisecond))
+	for _, task := range r.tasks {
+		fmt.Fprintf(w, "%*s |%s|\n", nameWidth, task, rows[task])
+	}
+	fmt.Fprintf(w, "%*s  # running  . blocked on I/O, one column = %s\n",
+		nameWidth, "", (total / time.Duration(width)).Round(time.Millisecond))
+}
+
+// exportTrace prints the Gantt chart and, if path is set, writes the Chrome trace there.
+func exportTrace(r *traceRecorder, path string, end time.Time) {
+	fmt.Println()
+	r.writeGantt(os.Stdout, 72, end)
+	if path == "" {
+		return
+	}
+	f, err := os.Create(path)
+	if err == nil {
+		err = r.writeChromeTrace(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Printf("trace: %v\n", err)
+		return
+	}
+	fmt.Printf("Chrome trace written to %s (open it in chrome://tracing or ui.perfetto.dev)\n", path)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fixedTrace is 100ms of three tasks: input blocked, then runs; world runs twice; idle never gets to run.
+func fixedTrace() *traceRecorder {
+	ms := func(n int) time.Time { return replayStart.Add(time.Duration(n) * time.Millisecond) }
+	r := newTraceRecorder(replayStart)
+	r.record("input", ms(0), ms(40), true)
+	r.record("world", ms(0), ms(10), false)
+	r.record("input", ms(40), ms(50), false)
+	r.record("world", ms(60), ms(70), false)
+	r.addTask("idle")
+	return r
+}
+
+func TestWriteChromeTrace(t *testing.T) {
+	var buf bytes.Buffer
+	if err := fixedTrace().writeChromeTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	type event struct {
+		Name string
+		Cat  string
+		Ph   string
+		Ts   float64
+		Dur  float64
+		Pid  int
+		Tid  int
+		Args map[string]any
+	}
+	var got struct {
+		TraceEvents     []event
+		DisplayTimeUnit string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("not valid JSON: %v\n%s", err, buf.Bytes())
+	}
+
+	thread := func(tid int, name string) event {
+		return event{Name: "thread_name", Ph: "M", Pid: 1, Tid: tid, Args: map[string]any{"name": name}}
+	}
+	want := []event{
+		thread(1, "input"),
+		thread(2, "world"),
+		thread(3, "idle"),
+		{Name: "input (blocked)", Cat: "blocked", Ph: "X", Ts: 0, Dur: 40000, Pid: 1, Tid: 1},
+		{Name: "world", Cat: "run", Ph: "X", Ts: 0, Dur: 10000, Pid: 1, Tid: 2},
+		{Name: "input", Cat: "run", Ph: "X", Ts: 40000, Dur: 10000, Pid: 1, Tid: 1},
+		{Name: "world", Cat: "run", Ph: "X", Ts: 60000, Dur: 10000, Pid: 1, Tid: 2},
+	}
+	if !slices.EqualFunc(got.TraceEvents, want, func(a, b event) bool {
+		return a.Name == b.Name && a.Cat == b.Cat && a.Ph == b.Ph && a.Ts == b.Ts && a.Dur == b.Dur &&
+			a.Pid == b.Pid && a.Tid == b.Tid && a.Args["name"] == b.Args["name"]
+	}) {
+		t.Errorf("traceEvents = %+v\nwant %+v", got.TraceEvents, want)
+	}
+	if got.DisplayTimeUnit != "ms" {
+		t.Errorf("displayTimeUnit = %q, want ms", got.DisplayTimeUnit)
+	}
+}
+
+func TestWriteGantt(t *testing.T) {
+	var buf bytes.Buffer
+	fixedTrace().writeGantt(&buf, 10, replayStart.Add(100*time.Millisecond))
+
+	want := "" +
+		"      |0    100ms|\n" +
+		"input |....##    |\n" +
+		"world |##    ##  |\n" +
+		" idle |          |\n" +
+		"       # running  . blocked on I/O, one column = 10ms\n"
+	if got := buf.String(); got != want {
+		t.Errorf("chart:\n%s\nwant:\n%s", got, want)
+	}
+}